@@ -0,0 +1,71 @@
+package context_free_grammar
+
+import (
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestActionMatcher(t *testing.T) {
+	const attrNumber, attrPrice AttributeID = 20, 21
+	var calls []ActionContext
+	thousands := NewActionMatcher(
+		NewSequenceMatcher([]Matcher{
+			NewDictMatcher(map[string][]ValueID{"100": {100}, "900": {900}}, attrNumber),
+			NewAllowedWordMatcher("тыс"),
+		}),
+		func(ctx ActionContext) (AttrValues, error) {
+			calls = append(calls, ctx)
+			number := ctx.Memory[attrNumber][len(ctx.Memory[attrNumber])-1]
+			if number > 500 {
+				return nil, errors.New("too expensive")
+			}
+			return AttrValues{attrPrice: {number * 1000}}, nil
+		},
+	)
+	root := NewFullTextMatcher([]Matcher{
+		NewAllowedWordsMatcher([]string{"до", "за"}),
+		thousands,
+	})
+
+	res := root.Match(NewInitialState(getTokens("за 100 тыс")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrNumber: {100}, attrPrice: {100000}})
+	require.Equal(t, []ActionContext{{
+		Tokens: []string{"100", "тыс"},
+		Start:  1,
+		End:    3,
+		Memory: AttrValues{attrNumber: {100}},
+	}}, calls)
+
+	res = root.Match(NewInitialState(getTokens("до 900 тыс")))
+	testNegativeParse(t, res)
+	require.Equal(t, "action", Label(thousands))
+}
+
+func TestActionMatcher_AnyOrderChild(t *testing.T) {
+	var calls []ActionContext
+	district := NewActionMatcher(
+		NewAnyOrderDictMatcher(map[string][]ValueID{"центр": {5}}, 3),
+		func(ctx ActionContext) (AttrValues, error) {
+			calls = append(calls, ctx)
+			return nil, nil
+		},
+	)
+	root := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("снять"),
+		district,
+		NewAllowedWordMatcher("квартиру"),
+	})
+
+	res := root.Match(NewInitialState(getTokens("снять квартиру центр")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{3: {5}})
+	require.Equal(t, []ActionContext{{
+		Tokens: []string{"центр"},
+		Start:  2,
+		End:    3,
+		Memory: AttrValues{3: {5}},
+	}}, calls)
+}
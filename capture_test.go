@@ -0,0 +1,60 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCaptureMatcher(t *testing.T) {
+	const attrStreet, attrHouse, attrBrand AttributeID = 40, 41, 42
+	house := NewDictMatcher(map[string][]ValueID{"5": {5}, "12": {12}}, attrHouse)
+	street := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("улица"),
+		NewCaptureMatcher(attrStreet, MaxTokens(3), StopAt(house), KeepMatchedTokens()),
+		house,
+	})
+
+	res := street.Match(NewInitialState(getTokens("улица Неизвестная 5")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrHouse: {5}})
+	require.Equal(t, AttrTexts{attrStreet: {"Неизвестная"}}, res.Memory().GetTexts())
+
+	res = street.Match(NewInitialState(getTokens("улица Красных Зорь 12")))
+	testPositiveParse(t, res)
+	require.Equal(t, AttrTexts{attrStreet: {"Красных Зорь"}}, res.Memory().GetTexts())
+
+	res = street.Match(NewInitialState(getTokens("улица Очень Длинного Названия Проезд 12")))
+	testNegativeParse(t, res)
+	require.Equal(t, `unexpected "Проезд" at position 4, expected dictionary(attribute=41)`, res.Diagnostics().String())
+
+	res = street.Match(NewInitialState(getTokens("улица 5")))
+	testNegativeParse(t, res)
+
+	brand := NewFullTextMatcher([]Matcher{
+		NewOneOfMatcher([]Matcher{
+			NewSequenceMatcher([]Matcher{NewCaptureMatcher(attrBrand, MaxTokens(1)), NewAllowedWordMatcher("!")}),
+			NewAllowedWordsMatcher([]string{"купить", "за"}),
+		}),
+		house,
+		NewCaptureMatcher(attrBrand, StopWords("за", "купить")),
+	})
+	res = brand.Match(NewInitialState(getTokens("купить Ultra Phone за 12")))
+	testPositiveParse(t, res)
+	require.Equal(t, AttrTexts{attrBrand: {"Ultra Phone"}}, res.Memory().GetTexts())
+	require.Equal(t, "capture text(attribute=42)", Label(NewCaptureMatcher(attrBrand)))
+}
+
+func TestCaptureMatcher_StopAtOnce(t *testing.T) {
+	const attrFrom, attrTo AttributeID = 43, 44
+	to := NewOnceMatcher(NewAllowedWordMatcher("до"))
+	route := NewSequenceMatcher([]Matcher{
+		NewCaptureMatcher(attrFrom, StopAt(to)),
+		to,
+		NewCaptureMatcher(attrTo, StopAt(to)),
+	})
+
+	res := route.Match(NewInitialState(getTokens("метро до дом до угла")))
+	testPositiveParse(t, res)
+	require.Equal(t, AttrTexts{attrFrom: {"метро"}, attrTo: {"дом до угла"}}, res.Memory().GetTexts())
+}
@@ -0,0 +1,570 @@
+package context_free_grammar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"math"
+	"os"
+	"reflect"
+	"regexp"
+)
+
+// Compiled grammar layout, little endian:
+//
+//	magic      "CFGC"
+//	version    uint32
+//	dictCount  uint32
+//	nodeCount  uint32
+//	root       uint32  node index
+//	treeSize   uint32
+//	directory  [dictCount]{offset, size uint64}  absolute positions of dictionary tables
+//	tree       nodes in post order, children are referenced by index
+//	tables     dictionary tables aligned to 8 bytes, see table.go
+const (
+	compiledMagic      = "CFGC"
+	compiledVersion    = 2
+	compiledHeaderSize = 24
+)
+
+const (
+	nodeWord byte = iota + 1
+	nodeWords
+	nodeDict
+	nodeAnyOrderDict
+	nodeSequence
+	nodeFullText
+	nodeOneOf
+	nodeOnce
+	nodeTryAll
+	nodePermutation
+	nodeRegexp
+	nodeCapture
+)
+
+const (
+	flagKeepMatchedTokens byte = 1 << iota
+	flagCalculateNeedleLength
+	flagSkipUnknownTokens
+	flagPrefixLastToken
+)
+
+var ErrCompiledFormat = errors.New("invalid compiled grammar")
+
+type grammarEncoder struct {
+	tree         []byte
+	nodes        map[Matcher]uint64
+	nodeCount    int
+	dictionaries map[Dictionary]uint64
+	tables       [][]byte
+}
+
+// WriteCompiledGrammar serializes the grammar with dictionaries and their precomputed indexes.
+// Reloadable dictionaries are saved as their current snapshot.
+func WriteCompiledGrammar(w io.Writer, root Matcher) error {
+	e := &grammarEncoder{
+		nodes:        make(map[Matcher]uint64),
+		dictionaries: make(map[Dictionary]uint64),
+	}
+	rootIndex, err := e.node(root)
+	if err != nil {
+		return err
+	}
+
+	header := make([]byte, compiledHeaderSize, compiledHeaderSize+16*len(e.tables))
+	copy(header, compiledMagic)
+	binary.LittleEndian.PutUint32(header[4:], compiledVersion)
+	binary.LittleEndian.PutUint32(header[8:], uint32(len(e.tables)))
+	binary.LittleEndian.PutUint32(header[12:], uint32(e.nodeCount))
+	binary.LittleEndian.PutUint32(header[16:], uint32(rootIndex))
+	binary.LittleEndian.PutUint32(header[20:], uint32(len(e.tree)))
+
+	offset := align8(compiledHeaderSize + 16*len(e.tables) + len(e.tree))
+	for _, table := range e.tables {
+		header = binary.LittleEndian.AppendUint64(header, uint64(offset))
+		header = binary.LittleEndian.AppendUint64(header, uint64(len(table)))
+		offset = align8(offset + len(table))
+	}
+
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	if _, err := w.Write(e.tree); err != nil {
+		return err
+	}
+	written := len(header) + len(e.tree)
+	for _, table := range e.tables {
+		padding := make([]byte, align8(written)-written)
+		if _, err := w.Write(padding); err != nil {
+			return err
+		}
+		if _, err := w.Write(table); err != nil {
+			return err
+		}
+		written += len(padding) + len(table)
+	}
+	return nil
+}
+
+func (e *grammarEncoder) node(m Matcher) (uint64, error) {
+	comparable := reflect.TypeOf(m).Comparable()
+	if comparable {
+		if index, ok := e.nodes[m]; ok {
+			return index, nil
+		}
+	}
+
+	var (
+		kind     byte
+		o        options
+		payload  []byte
+		children []Matcher
+	)
+	switch m := m.(type) {
+	case *allowedWordMatcher:
+		kind, o = nodeWord, m.o
+		payload = appendString(payload, m.word)
+	case *allowedWordsMatcher:
+		kind, o = nodeWords, m.o
+		payload = binary.AppendUvarint(payload, uint64(m.maxKeyLength))
+		payload = binary.AppendUvarint(payload, uint64(len(m.words)))
+		for _, word := range sortedKeys(m.words) {
+			payload = appendString(payload, word)
+		}
+	case *dictMatcher:
+		kind, o = nodeDict, m.o
+		payload = binary.AppendVarint(payload, m.attributeId)
+		payload = binary.AppendUvarint(payload, e.dictionary(m.dict))
+	case *anyOrderDictMatcher:
+		kind, o = nodeAnyOrderDict, m.o
+		payload = binary.AppendVarint(payload, m.attributeId)
+		payload = binary.AppendUvarint(payload, e.dictionary(m.dict))
+	case *sequenceMatcher:
+		kind, o, children = nodeSequence, m.o, m.words
+	case *fullTextMatcher:
+		kind, o, children = nodeFullText, m.o, m.nodes
+	case *oneOfMatcher:
+		kind, o, children = nodeOneOf, m.o, m.words
+	case *onceMatcher:
+		kind, o, children = nodeOnce, m.o, []Matcher{m.matcher}
+	case *tryAllMatcher:
+		kind, o, children = nodeTryAll, m.o, m.nodes
+	case *permutationMatcher:
+		kind, o = nodePermutation, m.o
+		children = append(append([]Matcher(nil), m.required...), m.optional...)
+		payload = binary.AppendUvarint(payload, uint64(len(m.required)))
+	case *regexpMatcher:
+		kind, o = nodeRegexp, m.o
+		payload = appendString(payload, m.expr.String())
+		payload = binary.AppendUvarint(payload, uint64(m.o.maxTokens))
+		payload = binary.AppendUvarint(payload, uint64(len(m.groups)))
+		for _, name := range sortedKeys(m.groups) {
+			payload = appendString(payload, name)
+			payload = binary.AppendVarint(payload, m.groups[name].Attribute)
+			payload = binary.AppendUvarint(payload, uint64(m.groups[name].Type))
+		}
+	case *captureMatcher:
+		kind, o, children = nodeCapture, m.o, []Matcher{}
+		payload = binary.AppendVarint(payload, m.attributeId)
+		payload = binary.AppendUvarint(payload, uint64(m.o.maxTokens))
+		payload = binary.AppendUvarint(payload, uint64(len(m.o.stopWords)))
+		for _, word := range sortedKeys(m.o.stopWords) {
+			payload = appendString(payload, word)
+		}
+		if m.o.stopAt != nil {
+			children = append(children, m.o.stopAt)
+		}
+	default:
+		return 0, fmt.Errorf("unsupported matcher %T", m)
+	}
+
+	if children != nil {
+		payload = binary.AppendUvarint(payload, uint64(len(children)))
+	}
+	for _, child := range children {
+		index, err := e.node(child)
+		if err != nil {
+			return 0, err
+		}
+		payload = binary.AppendUvarint(payload, index)
+	}
+
+	e.tree = append(e.tree, kind)
+	e.tree = appendOptions(e.tree, o)
+	e.tree = append(e.tree, payload...)
+	index := uint64(e.nodeCount)
+	e.nodeCount++
+	if comparable {
+		e.nodes[m] = index
+	}
+	return index, nil
+}
+
+func (e *grammarEncoder) dictionary(dict Dictionary) uint64 {
+	comparable := reflect.TypeOf(dict).Comparable()
+	if comparable {
+		if index, ok := e.dictionaries[dict]; ok {
+			return index
+		}
+	}
+	index := uint64(len(e.tables))
+	e.tables = append(e.tables, encodeDictionaryTable(dict))
+	if comparable {
+		e.dictionaries[dict] = index
+	}
+	return index
+}
+
+func appendString(b []byte, s string) []byte {
+	b = binary.AppendUvarint(b, uint64(len(s)))
+	return append(b, s...)
+}
+
+func appendOptions(b []byte, o options) []byte {
+	var flags byte
+	if o.keepMatchedTokens {
+		flags |= flagKeepMatchedTokens
+	}
+	if o.calculateNeedleLength {
+		flags |= flagCalculateNeedleLength
+	}
+	if o.skipUnknownTokens {
+		flags |= flagSkipUnknownTokens
+	}
+	if o.prefixLastToken {
+		flags |= flagPrefixLastToken
+	}
+	b = append(b, flags)
+	if o.skipUnknownTokens {
+		b = binary.LittleEndian.AppendUint64(b, math.Float64bits(o.maxSkippedRatio))
+	}
+	return appendString(b, o.name)
+}
+
+// ReadCompiledGrammar restores a grammar written by WriteCompiledGrammar.
+// Dictionaries are served directly from data, so it must not be modified while the grammar is in use.
+func ReadCompiledGrammar(data []byte) (Matcher, error) {
+	if len(data) < compiledHeaderSize || !bytes.Equal(data[:4], []byte(compiledMagic)) {
+		return nil, fmt.Errorf("%w: bad magic", ErrCompiledFormat)
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != compiledVersion {
+		return nil, fmt.Errorf("%w: unsupported version %d", ErrCompiledFormat, version)
+	}
+	dictCount := int(binary.LittleEndian.Uint32(data[8:]))
+	nodeCount := int(binary.LittleEndian.Uint32(data[12:]))
+	rootIndex := int(binary.LittleEndian.Uint32(data[16:]))
+	treeSize := int(binary.LittleEndian.Uint32(data[20:]))
+	treeStart := compiledHeaderSize + 16*dictCount
+	if treeStart+treeSize > len(data) || nodeCount > treeSize || rootIndex >= nodeCount {
+		return nil, fmt.Errorf("%w: truncated header", ErrCompiledFormat)
+	}
+
+	dictionaries := make([]Dictionary, dictCount)
+	for i := range dictionaries {
+		entry := data[compiledHeaderSize+16*i:]
+		offset, size := binary.LittleEndian.Uint64(entry), binary.LittleEndian.Uint64(entry[8:])
+		if offset > uint64(len(data)) || size > uint64(len(data))-offset {
+			return nil, fmt.Errorf("%w: dictionary %d is out of bounds", ErrCompiledFormat, i)
+		}
+		table, err := decodeDictionaryTable(data[offset : offset+size])
+		if err != nil {
+			return nil, fmt.Errorf("%w: dictionary %d: %v", ErrCompiledFormat, i, err)
+		}
+		dictionaries[i] = table
+	}
+
+	d := &grammarDecoder{
+		data:         data[treeStart : treeStart+treeSize],
+		dictionaries: dictionaries,
+		nodes:        make([]Matcher, 0, nodeCount),
+	}
+	for len(d.nodes) < nodeCount {
+		m := d.node()
+		if d.err != nil {
+			return nil, fmt.Errorf("%w: node %d: %v", ErrCompiledFormat, len(d.nodes), d.err)
+		}
+		d.nodes = append(d.nodes, m)
+	}
+	return d.nodes[rootIndex], nil
+}
+
+type grammarDecoder struct {
+	data         []byte
+	pos          int
+	err          error
+	dictionaries []Dictionary
+	nodes        []Matcher
+}
+
+func (d *grammarDecoder) fail(format string, args ...any) {
+	if d.err == nil {
+		d.err = fmt.Errorf(format, args...)
+	}
+}
+
+func (d *grammarDecoder) byte() byte {
+	if d.err != nil || d.pos >= len(d.data) {
+		d.fail("unexpected end of tree")
+		return 0
+	}
+	d.pos++
+	return d.data[d.pos-1]
+}
+
+func (d *grammarDecoder) uvarint() uint64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Uvarint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad varint at %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *grammarDecoder) varint() int64 {
+	if d.err != nil {
+		return 0
+	}
+	v, n := binary.Varint(d.data[d.pos:])
+	if n <= 0 {
+		d.fail("bad varint at %d", d.pos)
+		return 0
+	}
+	d.pos += n
+	return v
+}
+
+func (d *grammarDecoder) string() string {
+	size := d.uvarint()
+	if d.err != nil || size > uint64(len(d.data)-d.pos) {
+		d.fail("string is out of bounds")
+		return ""
+	}
+	d.pos += int(size)
+	return string(d.data[d.pos-int(size) : d.pos])
+}
+
+func (d *grammarDecoder) options() []Option {
+	flags := d.byte()
+	var opts []Option
+	if flags&flagKeepMatchedTokens != 0 {
+		opts = append(opts, KeepMatchedTokens())
+	}
+	if flags&flagCalculateNeedleLength != 0 {
+		opts = append(opts, CalculateNeedleLength())
+	}
+	if flags&flagPrefixLastToken != 0 {
+		opts = append(opts, PrefixLastToken())
+	}
+	if flags&flagSkipUnknownTokens != 0 {
+		if d.pos+8 > len(d.data) {
+			d.fail("unexpected end of tree")
+			return nil
+		}
+		opts = append(opts, SkipUnknownTokens(math.Float64frombits(binary.LittleEndian.Uint64(d.data[d.pos:]))))
+		d.pos += 8
+	}
+	if name := d.string(); name != "" {
+		opts = append(opts, WithName(name))
+	}
+	return opts
+}
+
+func (d *grammarDecoder) dictionary() Dictionary {
+	index := d.uvarint()
+	if d.err != nil || index >= uint64(len(d.dictionaries)) {
+		d.fail("unknown dictionary %d", index)
+		return nil
+	}
+	return d.dictionaries[index]
+}
+
+// children reads node indexes, which always point to already decoded nodes.
+func (d *grammarDecoder) children(count uint64) []Matcher {
+	if d.err != nil || count > uint64(len(d.nodes)) {
+		d.fail("too many children")
+		return nil
+	}
+	children := make([]Matcher, 0, count)
+	for i := uint64(0); i < count; i++ {
+		index := d.uvarint()
+		if d.err != nil || index >= uint64(len(d.nodes)) {
+			d.fail("unknown child %d", index)
+			return nil
+		}
+		children = append(children, d.nodes[index])
+	}
+	return children
+}
+
+func (d *grammarDecoder) node() Matcher {
+	kind := d.byte()
+	opts := d.options()
+
+	switch kind {
+	case nodeWord:
+		return NewAllowedWordMatcher(d.string(), opts...)
+	case nodeWords:
+		maxKeyLength := d.uvarint()
+		count := d.uvarint()
+		if d.err != nil || count > uint64(len(d.data)) {
+			d.fail("too many words")
+			return nil
+		}
+		o := &options{}
+		for _, opt := range opts {
+			opt(o)
+		}
+		m := &allowedWordsMatcher{
+			words:        make(map[string]struct{}, count),
+			maxKeyLength: int(maxKeyLength),
+			o:            *o,
+		}
+		for i := uint64(0); i < count; i++ {
+			m.words[d.string()] = struct{}{}
+		}
+		return m
+	case nodeDict:
+		attributeId := d.varint()
+		return NewDictMatcherFrom(d.dictionary(), attributeId, opts...)
+	case nodeAnyOrderDict:
+		attributeId := d.varint()
+		return NewAnyOrderDictMatcherFrom(d.dictionary(), attributeId, opts...)
+	case nodeSequence:
+		return NewSequenceMatcher(d.children(d.uvarint()), opts...)
+	case nodeFullText:
+		return NewFullTextMatcher(d.children(d.uvarint()), opts...)
+	case nodeOneOf:
+		return NewOneOfMatcher(d.children(d.uvarint()), opts...)
+	case nodeOnce:
+		children := d.children(d.uvarint())
+		if d.err != nil || len(children) != 1 {
+			d.fail("once node needs exactly one child")
+			return nil
+		}
+		return NewOnceMatcher(children[0], opts...)
+	case nodeTryAll:
+		return NewTryAllMatcher(d.children(d.uvarint()), opts...)
+	case nodePermutation:
+		required := d.uvarint()
+		children := d.children(d.uvarint())
+		if d.err != nil || required > uint64(len(children)) {
+			d.fail("bad permutation")
+			return nil
+		}
+		return NewPermutationMatcher(children[:required:required], children[required:], opts...)
+	case nodeRegexp:
+		expr, err := regexp.Compile(d.string())
+		if err != nil {
+			d.fail("bad regexp: %v", err)
+		}
+		if maxTokens := d.uvarint(); maxTokens > 0 {
+			opts = append(opts, MaxTokens(int(maxTokens)))
+		}
+		count := d.uvarint()
+		if d.err != nil || count > uint64(len(d.data)) {
+			d.fail("too many groups")
+			return nil
+		}
+		groups := make(map[string]RegexpGroup, count)
+		for i := uint64(0); i < count; i++ {
+			name := d.string()
+			attributeId := d.varint()
+			groups[name] = RegexpGroup{attributeId, ValueType(d.uvarint())}
+		}
+		if d.err != nil {
+			return nil
+		}
+		return NewRegexpMatcher(expr, groups, opts...)
+	case nodeCapture:
+		attributeId := d.varint()
+		if maxTokens := d.uvarint(); maxTokens > 0 {
+			opts = append(opts, MaxTokens(int(maxTokens)))
+		}
+		count := d.uvarint()
+		if d.err != nil || count > uint64(len(d.data)) {
+			d.fail("too many stop words")
+			return nil
+		}
+		stopWords := make([]string, 0, count)
+		for i := uint64(0); i < count; i++ {
+			stopWords = append(stopWords, d.string())
+		}
+		if count > 0 {
+			opts = append(opts, StopWords(stopWords...))
+		}
+		children := d.children(d.uvarint())
+		if d.err != nil || len(children) > 1 {
+			d.fail("capture node takes at most one child")
+			return nil
+		}
+		if len(children) == 1 {
+			opts = append(opts, StopAt(children[0]))
+		}
+		return NewCaptureMatcher(attributeId, opts...)
+	}
+	d.fail("unknown node kind %d", kind)
+	return nil
+}
+
+type CompiledGrammar struct {
+	Root    Matcher
+	release func() error
+}
+
+// Close releases the memory mapping, the grammar must not be used afterwards.
+func (g *CompiledGrammar) Close() error {
+	if g.release == nil {
+		return nil
+	}
+	release := g.release
+	g.release = nil
+	return release()
+}
+
+type compiledOptions struct {
+	memoryMapped bool
+}
+
+type CompiledOption func(opt *compiledOptions)
+
+// MemoryMapped maps the file into memory instead of reading it, dictionaries are paged in on demand.
+func MemoryMapped() CompiledOption {
+	return func(opt *compiledOptions) {
+		opt.memoryMapped = true
+	}
+}
+
+func OpenCompiledGrammar(path string, opts ...CompiledOption) (*CompiledGrammar, error) {
+	o := &compiledOptions{}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	var (
+		data    []byte
+		release func() error
+		err     error
+	)
+	if o.memoryMapped {
+		data, release, err = mapFile(path)
+	} else {
+		data, err = os.ReadFile(path)
+	}
+	if err != nil {
+		return nil, err
+	}
+	root, err := ReadCompiledGrammar(data)
+	if err != nil {
+		if release != nil {
+			release()
+		}
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &CompiledGrammar{Root: root, release: release}, nil
+}
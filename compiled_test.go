@@ -0,0 +1,125 @@
+package context_free_grammar
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func compiledTestGrammar() Matcher {
+	rooms := NewMapDictionary(map[string][]ValueID{"2к": {2}, "3к": {3}, "двухкомнатная квартира": {2, 20}})
+	return NewFullTextMatcher([]Matcher{
+		NewOnceMatcher(NewAllowedWordsMatcher([]string{"сниму", "хочу снять"}, KeepMatchedTokens())),
+		NewPermutationMatcher(
+			[]Matcher{NewDictMatcherFrom(rooms, 1, CalculateNeedleLength())},
+			[]Matcher{NewAnyOrderDictMatcher(map[string][]ValueID{"москва": {77}}, 2, WithName("city"))},
+			SkipUnknownTokens(0.5),
+		),
+		NewTryAllMatcher([]Matcher{NewAnyOrderDictMatcherFrom(rooms, 3, PrefixLastToken())}),
+		NewSequenceMatcher([]Matcher{NewAllowedWordMatcher("без"), NewAllowedWordMatcher("посредников")}),
+		NewRegexpMatcher(regexp.MustCompile(`(?P<area>\d+) ?м2`), map[string]RegexpGroup{"area": {Attribute: 4}}, MaxTokens(2)),
+	}, WithName("root"))
+}
+
+func TestCompiledGrammar_RoundTrip(t *testing.T) {
+	root := compiledTestGrammar()
+	var b bytes.Buffer
+	require.NoError(t, WriteCompiledGrammar(&b, root))
+
+	restored, err := ReadCompiledGrammar(b.Bytes())
+	require.NoError(t, err)
+
+	var expectedDOT, restoredDOT bytes.Buffer
+	require.NoError(t, WriteDOT(&expectedDOT, root))
+	require.NoError(t, WriteDOT(&restoredDOT, restored))
+	require.Equal(t, expectedDOT.String(), restoredDOT.String())
+
+	for _, query := range []string{
+		"хочу снять двухкомнатная квартира москва без посредников",
+		"сниму москва 3к уютная 2к",
+		"сниму 2к москва 4к",
+		"сниму 3к 50 м2 москва",
+		"сниму москва двухкомнатная кв",
+	} {
+		expected := compiledTestGrammar().Match(NewInitialState(getTokens(query)))
+		res := restored.Match(NewInitialState(getTokens(query)))
+		require.Equal(t, expected.HasMatch(), res.HasMatch(), query)
+		require.Equal(t, expected.Memory(), res.Memory(), query)
+		require.Equal(t, expected.SkippedTokens(), res.SkippedTokens(), query)
+		require.Equal(t, expected.Completions(), res.Completions(), query)
+	}
+
+	rooms := restored.(Composite).Children()[1].(Composite).Children()[0].(*dictMatcher).dict
+	require.Equal(t, 3, rooms.Len())
+	require.Equal(t, 2, rooms.MaxKeyLength())
+	require.Same(t, rooms, restored.(Composite).Children()[2].(Composite).Children()[0].(*anyOrderDictMatcher).dict)
+}
+
+func TestCompiledGrammar_Capture(t *testing.T) {
+	house := NewDictMatcher(map[string][]ValueID{"5": {5}, "12": {12}}, 41)
+	root := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("улица"),
+		NewCaptureMatcher(40, MaxTokens(3), StopWords("дом"), StopAt(house), WithName("street")),
+		NewOneOfMatcher([]Matcher{house, NewSequenceMatcher([]Matcher{NewAllowedWordMatcher("дом"), house})}),
+	})
+	var b bytes.Buffer
+	require.NoError(t, WriteCompiledGrammar(&b, root))
+
+	restored, err := ReadCompiledGrammar(b.Bytes())
+	require.NoError(t, err)
+	for _, query := range []string{
+		"улица Красных Зорь 12",
+		"улица Неизвестная дом 5",
+		"улица Очень Длинного Названия Проезд 12",
+	} {
+		expected := root.Match(NewInitialState(getTokens(query)))
+		res := restored.Match(NewInitialState(getTokens(query)))
+		require.Equal(t, expected.HasMatch(), res.HasMatch(), query)
+		require.Equal(t, expected.Memory(), res.Memory(), query)
+	}
+	capture := restored.(Composite).Children()[1]
+	require.Equal(t, "street: capture text(attribute=40)", Label(capture))
+	require.Same(t, restored.(Composite).Children()[2].(Composite).Children()[0], capture.(*captureMatcher).o.stopAt)
+}
+
+func TestCompiledGrammar_Invalid(t *testing.T) {
+	err := WriteCompiledGrammar(&bytes.Buffer{}, matcherFunc(func(state MatchState) MatchState { return state }))
+	require.ErrorContains(t, err, "unsupported matcher")
+
+	var b bytes.Buffer
+	require.NoError(t, WriteCompiledGrammar(&b, compiledTestGrammar()))
+	data := b.Bytes()
+
+	_, err = ReadCompiledGrammar([]byte("GRAMMAR"))
+	require.ErrorIs(t, err, ErrCompiledFormat)
+
+	for _, size := range []int{compiledHeaderSize, len(data) / 2, len(data) - 1} {
+		_, err = ReadCompiledGrammar(data[:size])
+		require.ErrorIs(t, err, ErrCompiledFormat)
+	}
+
+	newer := append([]byte(nil), data...)
+	newer[4] = compiledVersion + 1
+	_, err = ReadCompiledGrammar(newer)
+	require.ErrorContains(t, err, "unsupported version 3")
+}
+
+func TestOpenCompiledGrammar_MemoryMapped(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "grammar.cfgc")
+	var b bytes.Buffer
+	require.NoError(t, WriteCompiledGrammar(&b, compiledTestGrammar()))
+	require.NoError(t, os.WriteFile(path, b.Bytes(), 0o644))
+
+	for _, opts := range [][]CompiledOption{nil, {MemoryMapped()}} {
+		grammar, err := OpenCompiledGrammar(path, opts...)
+		require.NoError(t, err)
+		res := grammar.Root.Match(NewInitialState(getTokens("сниму 2к без посредников")))
+		testPositiveParse(t, res)
+		testDictParserResult(t, res, AttrValues{1: {2}})
+		require.NoError(t, grammar.Close())
+	}
+}
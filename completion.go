@@ -0,0 +1,27 @@
+package context_free_grammar
+
+type Completion struct {
+	Key       string
+	Attribute AttributeID
+	ValueIDs  []ValueID
+}
+
+// completeLastToken returns completions of the needle if it ends with the last token of the input.
+// Memory is left untouched since the completed key is not known yet.
+func completeLastToken(state MatchState, dict Dictionary, attributeId AttributeID, needle string) []Completion {
+	tokens := state.RemainingTokens()
+	if ctx := contextOf(state); ctx != nil && tokens[len(tokens)-1] != ctx.lastToken {
+		return nil
+	}
+	completions := append([]Completion(nil), state.Completions()...)
+	found := false
+	rangePrefix(dict, needle, func(key string, valueIds []ValueID) bool {
+		completions = append(completions, Completion{key, attributeId, valueIds})
+		found = true
+		return true
+	})
+	if !found {
+		return nil
+	}
+	return completions
+}
@@ -0,0 +1,65 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestPrefixLastToken(t *testing.T) {
+	rooms := map[string][]ValueID{
+		"2к":            {2},
+		"двухкомнатная": {2},
+		"двухкомнатная квартира": {2, 20},
+		"двухуровневая квартира": {22},
+		"трехкомнатная квартира": {3, 20},
+	}
+	root := NewFullTextMatcher([]Matcher{
+		NewAllowedWordsMatcher([]string{"сниму", "куплю"}),
+		NewDictMatcher(rooms, 1, PrefixLastToken(), CalculateNeedleLength()),
+	})
+
+	res := root.Match(NewInitialState(getTokens("сниму двух")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{})
+	require.Equal(t, []Completion{
+		{"двухкомнатная", 1, []ValueID{2}},
+		{"двухкомнатная квартира", 1, []ValueID{2, 20}},
+		{"двухуровневая квартира", 1, []ValueID{22}},
+	}, res.Completions())
+
+	res = root.Match(NewInitialState(getTokens("сниму двухкомнатная кв")))
+	testPositiveParse(t, res)
+	require.Equal(t, []Completion{{"двухкомнатная квартира", 1, []ValueID{2, 20}}}, res.Completions())
+
+	res = root.Match(NewInitialState(getTokens("сниму 2к")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{1: {2}})
+	require.Empty(t, res.Completions())
+
+	res = root.Match(NewInitialState(getTokens("двух сниму")))
+	testNegativeParse(t, res)
+
+	res = NewFullTextMatcher([]Matcher{
+		NewAllowedWordMatcher("сниму"),
+		NewAnyOrderDictMatcherFrom(NewReloadableDictionary("rooms", rooms), 1, PrefixLastToken()),
+	}).Match(NewInitialState(getTokens("сниму трех")))
+	testPositiveParse(t, res)
+	require.Equal(t, []Completion{{"трехкомнатная квартира", 1, []ValueID{3, 20}}}, res.Completions())
+}
+
+func TestRangePrefix(t *testing.T) {
+	entries := map[string][]ValueID{"a": {1}, "ab": {2}, "abc": {3}, "b": {4}}
+	mapped, err := OpenMappedDictionary(writeDictionaryTableFile(t, NewMapDictionary(entries)))
+	require.NoError(t, err)
+	defer mapped.Close()
+
+	for _, dict := range []Dictionary{NewMapDictionary(entries), mapped} {
+		var keys []string
+		rangePrefix(dict, "ab", func(key string, _ []ValueID) bool {
+			keys = append(keys, key)
+			return true
+		})
+		require.Equal(t, []string{"ab", "abc"}, keys)
+	}
+}
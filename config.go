@@ -0,0 +1,401 @@
+package context_free_grammar
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+type ConfigFormat int
+
+const (
+	FormatJSON ConfigFormat = iota
+	FormatYAML
+)
+
+type GrammarConfig struct {
+	Definitions map[string]*NodeConfig `json:"definitions,omitempty" yaml:"definitions,omitempty"`
+	Root        *NodeConfig            `json:"root" yaml:"root"`
+}
+
+type NodeConfig struct {
+	Type           string               `json:"type" yaml:"type"`
+	Name           string               `json:"name,omitempty" yaml:"name,omitempty"`
+	Ref            string               `json:"ref,omitempty" yaml:"ref,omitempty"`
+	Word           string               `json:"word,omitempty" yaml:"word,omitempty"`
+	Words          []string             `json:"words,omitempty" yaml:"words,omitempty"`
+	Attribute      *AttributeID         `json:"attribute,omitempty" yaml:"attribute,omitempty"`
+	Dictionary     map[string][]ValueID `json:"dictionary,omitempty" yaml:"dictionary,omitempty"`
+	DictionaryFile string               `json:"dictionaryFile,omitempty" yaml:"dictionaryFile,omitempty"`
+	Children       []*NodeConfig        `json:"children,omitempty" yaml:"children,omitempty"`
+	Child          *NodeConfig          `json:"child,omitempty" yaml:"child,omitempty"`
+	Required       []*NodeConfig        `json:"required,omitempty" yaml:"required,omitempty"`
+	Optional       []*NodeConfig        `json:"optional,omitempty" yaml:"optional,omitempty"`
+	Options        *NodeOptions         `json:"options,omitempty" yaml:"options,omitempty"`
+}
+
+type NodeOptions struct {
+	KeepMatchedTokens     bool     `json:"keepMatchedTokens,omitempty" yaml:"keepMatchedTokens,omitempty"`
+	CalculateNeedleLength bool     `json:"calculateNeedleLength,omitempty" yaml:"calculateNeedleLength,omitempty"`
+	MaxSkippedRatio       *float64 `json:"maxSkippedRatio,omitempty" yaml:"maxSkippedRatio,omitempty"`
+}
+
+type ConfigError struct {
+	Path string
+	Err  error
+}
+
+func (e *ConfigError) Error() string {
+	return e.Path + ": " + e.Err.Error()
+}
+
+func (e *ConfigError) Unwrap() error {
+	return e.Err
+}
+
+func configErrorf(path, format string, args ...any) error {
+	return &ConfigError{Path: path, Err: fmt.Errorf(format, args...)}
+}
+
+func LoadGrammarFile(path string) (Matcher, error) {
+	format, err := formatByExtension(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return LoadGrammar(f, format, filepath.Dir(path))
+}
+
+func LoadGrammar(r io.Reader, format ConfigFormat, baseDir string) (Matcher, error) {
+	config := &GrammarConfig{}
+	if err := decodeConfig(r, format, config); err != nil {
+		return nil, err
+	}
+	return BuildGrammar(config, baseDir)
+}
+
+func formatByExtension(path string) (ConfigFormat, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".json":
+		return FormatJSON, nil
+	case ".yaml", ".yml":
+		return FormatYAML, nil
+	}
+	return 0, fmt.Errorf("unsupported config file extension: %q", path)
+}
+
+func decodeConfig(r io.Reader, format ConfigFormat, v any) error {
+	switch format {
+	case FormatJSON:
+		decoder := json.NewDecoder(r)
+		decoder.DisallowUnknownFields()
+		return decoder.Decode(v)
+	case FormatYAML:
+		decoder := yaml.NewDecoder(r)
+		decoder.KnownFields(true)
+		return decoder.Decode(v)
+	}
+	return fmt.Errorf("unknown config format %d", format)
+}
+
+type grammarBuilder struct {
+	config   *GrammarConfig
+	baseDir  string
+	built    map[string]Matcher
+	building map[string]bool
+}
+
+func BuildGrammar(config *GrammarConfig, baseDir string) (Matcher, error) {
+	if config.Root == nil {
+		return nil, configErrorf("root", "root node is required")
+	}
+	b := &grammarBuilder{
+		config:   config,
+		baseDir:  baseDir,
+		built:    make(map[string]Matcher),
+		building: make(map[string]bool),
+	}
+	for _, name := range sortedKeys(config.Definitions) {
+		if _, err := b.definition(name, "definitions."+name); err != nil {
+			return nil, err
+		}
+	}
+	return b.node(config.Root, "root")
+}
+
+func (b *grammarBuilder) definition(name, path string) (Matcher, error) {
+	if m, ok := b.built[name]; ok {
+		return m, nil
+	}
+	node, ok := b.config.Definitions[name]
+	if !ok || node == nil {
+		return nil, configErrorf(path, "unknown definition %q", name)
+	}
+	if b.building[name] {
+		return nil, configErrorf(path, "reference cycle through definition %q", name)
+	}
+	b.building[name] = true
+	m, err := b.node(node, "definitions."+name)
+	b.building[name] = false
+	if err != nil {
+		return nil, err
+	}
+	b.built[name] = m
+	return m, nil
+}
+
+func (b *grammarBuilder) node(node *NodeConfig, path string) (Matcher, error) {
+	if node == nil {
+		return nil, configErrorf(path, "node is empty")
+	}
+	if err := checkNodeFields(node, path); err != nil {
+		return nil, err
+	}
+	opts, err := nodeOptions(node, path)
+	if err != nil {
+		return nil, err
+	}
+
+	switch node.Type {
+	case "ref":
+		return b.definition(node.Ref, path+".ref")
+	case "word":
+		return NewAllowedWordMatcher(node.Word, opts...), nil
+	case "words":
+		return NewAllowedWordsMatcher(node.Words, opts...), nil
+	case "dict", "anyOrderDict":
+		dict, err := b.dictionary(node, path)
+		if err != nil {
+			return nil, err
+		}
+		if node.Type == "dict" {
+			return NewDictMatcher(dict, *node.Attribute, opts...), nil
+		}
+		return NewAnyOrderDictMatcher(dict, *node.Attribute, opts...), nil
+	case "once":
+		child, err := b.node(node.Child, path+".child")
+		if err != nil {
+			return nil, err
+		}
+		return NewOnceMatcher(child, opts...), nil
+	case "permutation":
+		required, err := b.nodes(node.Required, path+".required")
+		if err != nil {
+			return nil, err
+		}
+		optional, err := b.nodes(node.Optional, path+".optional")
+		if err != nil {
+			return nil, err
+		}
+		return NewPermutationMatcher(required, optional, opts...), nil
+	}
+
+	children, err := b.nodes(node.Children, path+".children")
+	if err != nil {
+		return nil, err
+	}
+	switch node.Type {
+	case "sequence":
+		return NewSequenceMatcher(children, opts...), nil
+	case "fullText":
+		return NewFullTextMatcher(children, opts...), nil
+	case "oneOf":
+		return NewOneOfMatcher(children, opts...), nil
+	case "tryAll":
+		return NewTryAllMatcher(children, opts...), nil
+	}
+	return nil, configErrorf(path+".type", "unknown node type %q", node.Type)
+}
+
+func (b *grammarBuilder) nodes(nodes []*NodeConfig, path string) ([]Matcher, error) {
+	res := make([]Matcher, 0, len(nodes))
+	for i, node := range nodes {
+		m, err := b.node(node, fmt.Sprintf("%s[%d]", path, i))
+		if err != nil {
+			return nil, err
+		}
+		res = append(res, m)
+	}
+	return res, nil
+}
+
+func (b *grammarBuilder) dictionary(node *NodeConfig, path string) (map[string][]ValueID, error) {
+	if node.DictionaryFile == "" {
+		return node.Dictionary, nil
+	}
+	filePath := node.DictionaryFile
+	if !filepath.IsAbs(filePath) {
+		filePath = filepath.Join(b.baseDir, filePath)
+	}
+	dict, err := LoadDictionaryFile(filePath, *node.Attribute)
+	if err != nil {
+		return nil, &ConfigError{Path: path + ".dictionaryFile", Err: err}
+	}
+	return dict, nil
+}
+
+func LoadDictionaryFile(path string, attributeId AttributeID) (map[string][]ValueID, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".csv", ".tsv":
+		dictionaries, report, err := ReadDictionariesFile(path, WithDefaultAttribute(attributeId))
+		if err != nil {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		if len(report.Malformed) > 0 {
+			return nil, fmt.Errorf("%s: %s", path, report.Malformed[0])
+		}
+		if len(report.Duplicates) > 0 {
+			return nil, fmt.Errorf("%s: %s", path, report.Duplicates[0])
+		}
+		for _, other := range sortedKeys(dictionaries) {
+			if other != attributeId {
+				return nil, fmt.Errorf("%s: has entries for attribute %d, expected %d only", path, other, attributeId)
+			}
+		}
+		if dictionaries[attributeId] == nil {
+			return nil, fmt.Errorf("%s: no entries for attribute %d", path, attributeId)
+		}
+		return dictionaries[attributeId], nil
+	}
+
+	format, err := formatByExtension(path)
+	if err != nil {
+		return nil, err
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	dict := make(map[string][]ValueID)
+	if err := decodeConfig(f, format, &dict); err != nil {
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return dict, nil
+}
+
+var nodeFields = map[string][]string{
+	"ref":          {"ref"},
+	"word":         {"word"},
+	"words":        {"words"},
+	"dict":         {"attribute", "dictionary", "dictionaryFile"},
+	"anyOrderDict": {"attribute", "dictionary", "dictionaryFile"},
+	"sequence":     {"children"},
+	"fullText":     {"children"},
+	"oneOf":        {"children"},
+	"tryAll":       {"children"},
+	"once":         {"child"},
+	"permutation":  {"required", "optional"},
+}
+
+func checkNodeFields(node *NodeConfig, path string) error {
+	allowed, ok := nodeFields[node.Type]
+	if !ok {
+		if node.Type == "" {
+			return configErrorf(path+".type", "node type is required")
+		}
+		return configErrorf(path+".type", "unknown node type %q", node.Type)
+	}
+
+	set := map[string]bool{
+		"ref":            node.Ref != "",
+		"word":           node.Word != "",
+		"words":          node.Words != nil,
+		"attribute":      node.Attribute != nil,
+		"dictionary":     node.Dictionary != nil,
+		"dictionaryFile": node.DictionaryFile != "",
+		"children":       node.Children != nil,
+		"child":          node.Child != nil,
+		"required":       node.Required != nil,
+		"optional":       node.Optional != nil,
+	}
+	for _, field := range allowed {
+		delete(set, field)
+	}
+	for _, field := range sortedKeys(set) {
+		if set[field] {
+			return configErrorf(path+"."+field, "field is not allowed for %s node", node.Type)
+		}
+	}
+
+	switch node.Type {
+	case "ref":
+		if node.Ref == "" {
+			return configErrorf(path+".ref", "reference name is required")
+		}
+	case "word":
+		if node.Word == "" {
+			return configErrorf(path+".word", "word is required")
+		}
+	case "words":
+		if len(node.Words) == 0 {
+			return configErrorf(path+".words", "at least one word is required")
+		}
+	case "dict", "anyOrderDict":
+		if node.Attribute == nil {
+			return configErrorf(path+".attribute", "attribute is required")
+		}
+		if (node.Dictionary == nil) == (node.DictionaryFile == "") {
+			return configErrorf(path, "exactly one of dictionary and dictionaryFile is required")
+		}
+	case "once":
+		if node.Child == nil {
+			return configErrorf(path+".child", "child is required")
+		}
+	case "permutation":
+		if len(node.Required)+len(node.Optional) == 0 {
+			return configErrorf(path, "at least one required or optional node is required")
+		}
+	default:
+		if len(node.Children) == 0 {
+			return configErrorf(path+".children", "at least one child is required")
+		}
+	}
+	return nil
+}
+
+func nodeOptions(node *NodeConfig, path string) ([]Option, error) {
+	if node.Type == "ref" {
+		switch {
+		case node.Name != "":
+			return nil, configErrorf(path+".name", "field is not allowed for ref node")
+		case node.Options != nil:
+			return nil, configErrorf(path+".options", "field is not allowed for ref node")
+		}
+		return nil, nil
+	}
+
+	var opts []Option
+	if node.Name != "" {
+		opts = append(opts, WithName(node.Name))
+	}
+	if node.Options == nil {
+		return opts, nil
+	}
+	if node.Options.KeepMatchedTokens {
+		opts = append(opts, KeepMatchedTokens())
+	}
+	if node.Options.CalculateNeedleLength {
+		opts = append(opts, CalculateNeedleLength())
+	}
+	if ratio := node.Options.MaxSkippedRatio; ratio != nil {
+		if node.Type != "fullText" {
+			return nil, configErrorf(path+".options.maxSkippedRatio", "option is allowed for fullText node only")
+		}
+		if *ratio < 0 || *ratio > 1 {
+			return nil, configErrorf(path+".options.maxSkippedRatio", "ratio must be between 0 and 1, got %v", *ratio)
+		}
+		opts = append(opts, SkipUnknownTokens(*ratio))
+	}
+	return opts, nil
+}
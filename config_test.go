@@ -0,0 +1,168 @@
+package context_free_grammar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoadGrammarFile_YAML(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "magic.json"),
+		[]byte(`{"abra": [1], "cadabra": [2]}`),
+		0o644,
+	))
+	require.NoError(t, os.WriteFile(filepath.Join(dir, "grammar.yaml"), []byte(`
+definitions:
+  lorem:
+    type: word
+    word: lorem
+root:
+  type: fullText
+  name: root
+  children:
+    - type: word
+      word: allowed
+    - type: once
+      child:
+        type: oneOf
+        children:
+          - type: sequence
+            children:
+              - type: ref
+                ref: lorem
+              - type: word
+                word: ipsum
+              - type: word
+                word: dolor
+          - type: ref
+            ref: lorem
+    - type: dict
+      attribute: 100500
+      dictionaryFile: magic.json
+      options:
+        keepMatchedTokens: true
+        calculateNeedleLength: true
+    - type: words
+      words: [awesome, matcher]
+`), 0o644))
+
+	root, err := LoadGrammarFile(filepath.Join(dir, "grammar.yaml"))
+	require.NoError(t, err)
+	require.Equal(t, "root", root.(Named).Name())
+
+	res := root.Match(NewInitialState(getTokens("awesome abra cadabra allowed lorem ipsum dolor matcher")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{100500: {1, 2}})
+	require.Equal(t, []string{"abra", "cadabra"}, res.MatchedTokens())
+}
+
+func TestLoadGrammar_JSON(t *testing.T) {
+	config := `{
+		"root": {
+			"type": "fullText",
+			"options": {"maxSkippedRatio": 0.5},
+			"children": [
+				{
+					"type": "permutation",
+					"required": [{"type": "anyOrderDict", "attribute": 1, "dictionary": {"2к": [2]}}],
+					"optional": [{"type": "dict", "attribute": 2, "dictionary": {"москва": [10]}}]
+				}
+			]
+		}
+	}`
+
+	root, err := LoadGrammar(strings.NewReader(config), FormatJSON, "")
+	require.NoError(t, err)
+
+	res := root.Match(NewInitialState(getTokens("москва 2к уютная")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{1: {2}, 2: {10}})
+	require.Equal(t, []SkippedToken{{Token: "уютная", Position: 2}}, res.SkippedTokens())
+}
+
+func TestLoadGrammar_Errors(t *testing.T) {
+	tests := []struct {
+		name     string
+		config   string
+		expected string
+	}{
+		{
+			name:     "missing root",
+			config:   `definitions: {}`,
+			expected: "root: root node is required",
+		},
+		{
+			name: "unknown type",
+			config: `
+root:
+  type: sequence
+  children:
+    - type: word
+      word: lorem
+    - type: regexp`,
+			expected: `root.children[1].type: unknown node type "regexp"`,
+		},
+		{
+			name: "missing attribute",
+			config: `
+root:
+  type: oneOf
+  children:
+    - type: dict
+      dictionary: {lorem: [1]}`,
+			expected: "root.children[0].attribute: attribute is required",
+		},
+		{
+			name: "field not allowed",
+			config: `
+root:
+  type: once
+  word: lorem
+  child: {type: word, word: lorem}`,
+			expected: "root.word: field is not allowed for once node",
+		},
+		{
+			name: "skip ratio on sequence",
+			config: `
+root:
+  type: sequence
+  options: {maxSkippedRatio: 0.5}
+  children: [{type: word, word: lorem}]`,
+			expected: "root.options.maxSkippedRatio: option is allowed for fullText node only",
+		},
+		{
+			name: "unknown definition",
+			config: `
+root:
+  type: fullText
+  children: [{type: ref, ref: ipsum}]`,
+			expected: `root.children[0].ref: unknown definition "ipsum"`,
+		},
+		{
+			name: "reference cycle",
+			config: `
+definitions:
+  a: {type: once, child: {type: ref, ref: a}}
+root: {type: ref, ref: a}`,
+			expected: `definitions.a.child.ref: reference cycle through definition "a"`,
+		},
+		{
+			name: "missing dictionary file",
+			config: `
+root: {type: dict, attribute: 1, dictionaryFile: missing.yaml}`,
+			expected: "root.dictionaryFile: open missing.yaml: no such file or directory",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := LoadGrammar(strings.NewReader(tt.config), FormatYAML, "")
+			require.EqualError(t, err, tt.expected)
+		})
+	}
+}
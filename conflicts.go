@@ -0,0 +1,136 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type DictionaryInfo struct {
+	Path       string
+	Matcher    Matcher
+	Attribute  AttributeID
+	Dictionary Dictionary
+}
+
+func CollectDictionaries(root Matcher) []DictionaryInfo {
+	var res []DictionaryInfo
+	walkPaths(root, func(m, _ Matcher, path string) {
+		switch m := m.(type) {
+		case *dictMatcher:
+			res = append(res, DictionaryInfo{path, m, m.attributeId, m.dict})
+		case *anyOrderDictMatcher:
+			res = append(res, DictionaryInfo{path, m, m.attributeId, m.dict})
+		}
+	})
+	return res
+}
+
+type ConflictKind string
+
+const (
+	ConflictAttributes    ConflictKind = "attributes"
+	ConflictValues        ConflictKind = "values"
+	ConflictPrefixOverlap ConflictKind = "prefix-overlap"
+)
+
+type KeyConflict struct {
+	Kind ConflictKind
+	Key  string
+	// BlockedKey is the longer key hidden by Key, set for prefix overlaps only.
+	BlockedKey   string
+	Dictionaries []DictionaryInfo
+}
+
+func (c KeyConflict) String() string {
+	places := make([]string, 0, len(c.Dictionaries))
+	for _, dict := range c.Dictionaries {
+		places = append(places, fmt.Sprintf("%s (attribute=%d)", dict.Path, dict.Attribute))
+	}
+	switch c.Kind {
+	case ConflictAttributes:
+		return fmt.Sprintf("key %q maps to different attributes in %s", c.Key, strings.Join(places, ", "))
+	case ConflictValues:
+		return fmt.Sprintf("key %q maps to different values in %s", c.Key, strings.Join(places, ", "))
+	}
+	return fmt.Sprintf("key %q in %s blocks key %q in %s", c.Key, places[0], c.BlockedKey, places[1])
+}
+
+func FindKeyConflicts(root Matcher) []KeyConflict {
+	dictionaries := CollectDictionaries(root)
+
+	byKey := make(map[string][]int)
+	for i, dict := range dictionaries {
+		dict.Dictionary.Range(func(key string, _ []ValueID) bool {
+			byKey[key] = append(byKey[key], i)
+			return true
+		})
+	}
+
+	var conflicts []KeyConflict
+	for _, key := range sortedKeys(byKey) {
+		indexes := byKey[key]
+		if len(indexes) < 2 {
+			continue
+		}
+		places := make([]DictionaryInfo, 0, len(indexes))
+		sameAttribute, sameValues := true, true
+		for _, i := range indexes {
+			places = append(places, dictionaries[i])
+			first := dictionaries[indexes[0]]
+			if dictionaries[i].Attribute != first.Attribute {
+				sameAttribute = false
+			} else if !sameValueSet(lookupValues(dictionaries[i].Dictionary, key), lookupValues(first.Dictionary, key)) {
+				sameValues = false
+			}
+		}
+		switch {
+		case !sameAttribute:
+			conflicts = append(conflicts, KeyConflict{Kind: ConflictAttributes, Key: key, Dictionaries: places})
+		case !sameValues:
+			conflicts = append(conflicts, KeyConflict{Kind: ConflictValues, Key: key, Dictionaries: places})
+		}
+	}
+
+	for _, key := range sortedKeys(byKey) {
+		tokens := strings.Split(key, " ")
+		for length := 1; length < len(tokens); length++ {
+			prefix := strings.Join(tokens[:length], " ")
+			for _, blocking := range byKey[prefix] {
+				for _, blocked := range byKey[key] {
+					if blocking >= blocked {
+						continue
+					}
+					conflicts = append(conflicts, KeyConflict{
+						Kind:         ConflictPrefixOverlap,
+						Key:          prefix,
+						BlockedKey:   key,
+						Dictionaries: []DictionaryInfo{dictionaries[blocking], dictionaries[blocked]},
+					})
+				}
+			}
+		}
+	}
+	return conflicts
+}
+
+func sameValueSet(a, b []ValueID) bool {
+	if len(a) != len(b) {
+		return false
+	}
+	a = append([]ValueID(nil), a...)
+	b = append([]ValueID(nil), b...)
+	sort.Slice(a, func(i, j int) bool { return a[i] < a[j] })
+	sort.Slice(b, func(i, j int) bool { return b[i] < b[j] })
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+func lookupValues(dict Dictionary, key string) []ValueID {
+	valueIds, _ := dict.Lookup(key)
+	return valueIds
+}
@@ -0,0 +1,57 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestCollectDictionaries(t *testing.T) {
+	rooms := NewDictMatcher(map[string][]ValueID{"2к": {2}}, 1, WithName("rooms"))
+	root := NewFullTextMatcher([]Matcher{
+		rooms,
+		NewOnceMatcher(NewAnyOrderDictMatcher(map[string][]ValueID{"снять": {1}}, 2)),
+		NewSequenceMatcher([]Matcher{NewAllowedWordMatcher("в"), rooms}),
+	})
+
+	dictionaries := CollectDictionaries(root)
+	require.Len(t, dictionaries, 2)
+	require.Equal(t, "fullText/rooms[0]", dictionaries[0].Path)
+	require.Equal(t, AttributeID(1), dictionaries[0].Attribute)
+	require.Equal(t, "fullText/once[1]/anyOrderDictionary[0]", dictionaries[1].Path)
+	valueIds, ok := dictionaries[1].Dictionary.Lookup("снять")
+	require.True(t, ok)
+	require.Equal(t, []ValueID{1}, valueIds)
+}
+
+func TestFindKeyConflicts(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewDictMatcher(map[string][]ValueID{
+			"студия": {0},
+			"2":      {2},
+		}, 1, WithName("rooms")),
+		NewDictMatcher(map[string][]ValueID{
+			"студия":      {10},
+			"2 комнатная": {2},
+		}, 2, WithName("type")),
+		NewDictMatcher(map[string][]ValueID{
+			"2": {3},
+		}, 1, WithName("rooms_extra")),
+	})
+
+	conflicts := FindKeyConflicts(root)
+	require.Len(t, conflicts, 3)
+
+	require.Equal(t, ConflictValues, conflicts[0].Kind)
+	require.Equal(t, "2", conflicts[0].Key)
+	require.Equal(t, `key "2" maps to different values in fullText/rooms[0] (attribute=1), fullText/rooms_extra[2] (attribute=1)`, conflicts[0].String())
+
+	require.Equal(t, ConflictAttributes, conflicts[1].Kind)
+	require.Equal(t, "студия", conflicts[1].Key)
+	require.Equal(t, `key "студия" maps to different attributes in fullText/rooms[0] (attribute=1), fullText/type[1] (attribute=2)`, conflicts[1].String())
+
+	require.Equal(t, ConflictPrefixOverlap, conflicts[2].Kind)
+	require.Equal(t, "2", conflicts[2].Key)
+	require.Equal(t, "2 комнатная", conflicts[2].BlockedKey)
+	require.Equal(t, `key "2" in fullText/rooms[0] (attribute=1) blocks key "2 комнатная" in fullText/type[1] (attribute=2)`, conflicts[2].String())
+}
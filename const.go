@@ -3,4 +3,5 @@ package context_free_grammar
 type AttributeID = int64
 type ValueID = int64
 type AttrValues = map[AttributeID][]ValueID
+type AttrTexts = map[AttributeID][]string
 
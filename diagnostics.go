@@ -0,0 +1,178 @@
+package context_free_grammar
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+)
+
+const maxDescribedWords = 5
+
+type Diagnostics struct {
+	// Position is the furthest token index reached by any branch of the parse.
+	Position int
+	// Token is the token at Position, empty when the input ended there.
+	Token    string
+	Expected []string
+	// Conflicts lists the writes rejected by MergeError, which fail the match of recognized tokens.
+	Conflicts []MergeConflict
+}
+
+type MergeConflict struct {
+	// Position is the index of the first token of the rejected match.
+	Position int
+	Err      *MergeConflictError
+}
+
+func (c MergeConflict) String() string {
+	return fmt.Sprintf("merge conflict at position %d: %s", c.Position, c.Err)
+}
+
+func (d *Diagnostics) String() string {
+	var parts []string
+	if len(d.Expected) > 0 {
+		found := "end of input"
+		if d.Token != "" {
+			found = fmt.Sprintf("%q", d.Token)
+		}
+		parts = append(parts, fmt.Sprintf(
+			"unexpected %s at position %d, expected %s",
+			found,
+			d.Position,
+			strings.Join(d.Expected, ", "),
+		))
+	}
+	for _, conflict := range d.Conflicts {
+		parts = append(parts, conflict.String())
+	}
+	return strings.Join(parts, "; ")
+}
+
+// reportConflict records a memory write rejected for the match starting offset tokens after the state,
+// errors other than merge conflicts are ignored.
+func reportConflict(state MatchState, offset int, err error) {
+	ctx := contextOf(state)
+	var conflictErr *MergeConflictError
+	if ctx == nil || !errors.As(err, &conflictErr) {
+		return
+	}
+	conflict := MergeConflict{ctx.inputLength - len(state.RemainingTokens()) + offset, conflictErr}
+	for _, c := range ctx.conflicts {
+		if c.Position == conflict.Position && c.Err.Error() == conflictErr.Error() {
+			return
+		}
+	}
+	ctx.conflicts = append(ctx.conflicts, conflict)
+}
+
+func reportFailure(state MatchState, expected string) {
+	ctx := contextOf(state)
+	if ctx == nil {
+		return
+	}
+	tokens := state.RemainingTokens()
+	position := ctx.inputLength - len(tokens)
+
+	if ctx.failure == nil || position > ctx.failure.Position {
+		token := ""
+		if len(tokens) > 0 {
+			token = tokens[0]
+		}
+		ctx.failure = &Diagnostics{
+			Position: position,
+			Token:    token,
+		}
+	} else if position < ctx.failure.Position {
+		return
+	}
+
+	for _, e := range ctx.failure.Expected {
+		if e == expected {
+			return
+		}
+	}
+	ctx.failure.Expected = append(ctx.failure.Expected, expected)
+}
+
+// reportExpected reports what the matcher accepts first, for combinators failing
+// before any child runs, like at the end of input.
+func reportExpected(state MatchState, m Matcher) {
+	switch m := m.(type) {
+	case *sequenceMatcher:
+		if len(m.words) > 0 {
+			reportExpected(state, m.words[0])
+		}
+	case *oneOfMatcher:
+		for _, node := range m.words {
+			reportExpected(state, node)
+		}
+	case *fullTextMatcher:
+		for _, node := range m.nodes {
+			reportExpected(state, node)
+		}
+	case *tryAllMatcher:
+		for _, node := range m.nodes {
+			reportExpected(state, node)
+		}
+	case *permutationMatcher:
+		nodes := m.required
+		if len(nodes) == 0 {
+			nodes = m.optional
+		}
+		for _, node := range nodes {
+			reportExpected(state, node)
+		}
+	case *onceMatcher:
+		if !m.used(state) {
+			reportExpected(state, m.matcher)
+		}
+	case *guardMatcher:
+		reportExpected(state, m.matcher)
+	case *actionMatcher:
+		reportExpected(state, m.matcher)
+	case interface{ expected() string }:
+		reportFailure(state, m.expected())
+	default:
+		reportFailure(state, Label(m))
+	}
+}
+
+func (w *allowedWordMatcher) expected() string {
+	return fmt.Sprintf("%q", w.word)
+}
+
+func (w *allowedWordsMatcher) expected() string {
+	if len(w.words) > maxDescribedWords {
+		return fmt.Sprintf("one of %d words", len(w.words))
+	}
+	words := make([]string, 0, len(w.words))
+	for word := range w.words {
+		words = append(words, fmt.Sprintf("%q", word))
+	}
+	sort.Strings(words)
+	return strings.Join(words, ", ")
+}
+
+func (m *dictMatcher) expected() string {
+	return fmt.Sprintf("dictionary(attribute=%d)", m.attributeId)
+}
+
+func (m *anyOrderDictMatcher) expected() string {
+	return fmt.Sprintf("dictionary(attribute=%d)", m.attributeId)
+}
+
+func (g *guardMatcher) expected() string {
+	if g.o.name != "" {
+		return fmt.Sprintf("guard(%s)", g.o.name)
+	}
+	return fmt.Sprintf("guard(%s)", Label(g.matcher))
+}
+
+func (c *captureMatcher) expected() string {
+	return fmt.Sprintf("text(attribute=%d)", c.attributeId)
+}
+
+func (r *regexpMatcher) expected() string {
+	return "/" + r.expr.String() + "/"
+}
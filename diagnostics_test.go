@@ -0,0 +1,106 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestDiagnostics_FurthestFailure(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewSequenceMatcher([]Matcher{
+			NewAllowedWordMatcher("lorem"),
+			NewAllowedWordMatcher("ipsum"),
+			NewAllowedWordMatcher("dolor"),
+		}),
+		NewDictMatcher(map[string][]ValueID{"abra": {1}}, 100500),
+	})
+
+	res := root.Match(NewInitialState(getTokens("abra lorem ipsum amet")))
+	testNegativeParse(t, res)
+	require.Equal(t, getTokens("abra lorem ipsum amet"), res.RemainingTokens())
+	require.Equal(t, &Diagnostics{
+		Position: 3,
+		Token:    "amet",
+		Expected: []string{`"dolor"`},
+	}, res.Diagnostics())
+	require.Equal(t, `unexpected "amet" at position 3, expected "dolor"`, res.Diagnostics().String())
+}
+
+func TestDiagnostics_Alternatives(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewAllowedWordMatcher("lorem"),
+		NewAllowedWordsMatcher([]string{"goes brr", "matcher"}),
+		NewDictMatcher(map[string][]ValueID{"abra": {1}}, 100500),
+	})
+
+	res := root.Match(NewInitialState(getTokens("lorem matcher unknown")))
+	testNegativeParse(t, res)
+	require.Equal(t, &Diagnostics{
+		Position: 2,
+		Token:    "unknown",
+		Expected: []string{`"lorem"`, `"goes brr", "matcher"`, "dictionary(attribute=100500)"},
+	}, res.Diagnostics())
+}
+
+func TestDiagnostics_EndOfInput(t *testing.T) {
+	root := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("lorem"),
+		NewAllowedWordMatcher("ipsum"),
+	})
+
+	res := root.Match(NewInitialState(getTokens("lorem")))
+	require.False(t, res.HasMatch())
+	require.Equal(t, 1, res.Diagnostics().Position)
+	require.Equal(t, "", res.Diagnostics().Token)
+	require.Equal(t, `unexpected end of input at position 1, expected "ipsum"`, res.Diagnostics().String())
+}
+
+func TestDiagnostics_NestedEndOfInput(t *testing.T) {
+	root := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("x"),
+		NewPermutationMatcher(
+			[]Matcher{NewDictMatcher(map[string][]ValueID{"abra": {1}}, 100500)},
+			[]Matcher{NewAllowedWordMatcher("lorem")},
+		),
+	})
+	res := root.Match(NewInitialState(getTokens("x")))
+	testNegativeParse(t, res)
+	require.Equal(t, `unexpected end of input at position 1, expected dictionary(attribute=100500)`, res.Diagnostics().String())
+
+	root = NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("x"),
+		NewSequenceMatcher([]Matcher{
+			NewOneOfMatcher([]Matcher{NewAllowedWordMatcher("lorem"), NewOnceMatcher(NewAllowedWordMatcher("ipsum"))}),
+			NewAllowedWordMatcher("dolor"),
+		}),
+	})
+	res = root.Match(NewInitialState(getTokens("x")))
+	testNegativeParse(t, res)
+	require.Equal(t, `unexpected end of input at position 1, expected "lorem", "ipsum"`, res.Diagnostics().String())
+}
+
+func TestDiagnostics_NoFailure(t *testing.T) {
+	res := NewAllowedWordMatcher("lorem").Match(NewInitialState(getTokens("lorem")))
+	testPositiveParse(t, res)
+	require.Nil(t, res.Diagnostics())
+
+	root := NewFullTextMatcher([]Matcher{
+		NewAllowedWordMatcher("lorem"),
+		NewDictMatcher(map[string][]ValueID{"abra": {1}}, 1),
+	})
+	res = root.Match(NewInitialState(getTokens("abra lorem")))
+	testPositiveParse(t, res)
+	require.Nil(t, res.Diagnostics())
+}
+
+func TestDiagnostics_CustomMatcher(t *testing.T) {
+	skip := matcherFunc(func(state MatchState) MatchState {
+		return NewMatchStateFrom(state, true, state.RemainingTokens()[1:], nil, state.Memory())
+	})
+	root := NewSequenceMatcher([]Matcher{skip, NewAllowedWordMatcher("lorem")})
+
+	res := root.Match(NewInitialState(getTokens("abra ipsum")))
+	testNegativeParse(t, res)
+	require.Equal(t, `unexpected "ipsum" at position 1, expected "lorem"`, res.Diagnostics().String())
+}
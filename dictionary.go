@@ -0,0 +1,231 @@
+package context_free_grammar
+
+import (
+	"context"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+type Dictionary interface {
+	Lookup(key string) ([]ValueID, bool)
+	Len() int
+	MaxKeyLength() int
+	Range(fn func(key string, valueIds []ValueID) bool)
+}
+
+// PrefixDictionary can list keys by prefix without scanning the whole dictionary.
+type PrefixDictionary interface {
+	Dictionary
+	// RangePrefix calls fn for keys starting with prefix in ascending order.
+	RangePrefix(prefix string, fn func(key string, valueIds []ValueID) bool)
+}
+
+type mapDictionary struct {
+	entries      map[string][]ValueID
+	maxKeyLength int
+	once         sync.Once
+	sortedKeys   []string
+	indexOnce    sync.Once
+}
+
+func NewMapDictionary(entries map[string][]ValueID) Dictionary {
+	return &mapDictionary{entries: entries}
+}
+
+func (d *mapDictionary) Lookup(key string) ([]ValueID, bool) {
+	valueIds, ok := d.entries[key]
+	return valueIds, ok
+}
+
+func (d *mapDictionary) Len() int {
+	return len(d.entries)
+}
+
+func (d *mapDictionary) MaxKeyLength() int {
+	d.once.Do(func() {
+		for key := range d.entries {
+			d.maxKeyLength = max(d.maxKeyLength, countKeyTokens(key))
+		}
+	})
+	return d.maxKeyLength
+}
+
+func (d *mapDictionary) Range(fn func(key string, valueIds []ValueID) bool) {
+	for key, valueIds := range d.entries {
+		if !fn(key, valueIds) {
+			return
+		}
+	}
+}
+
+func (d *mapDictionary) RangePrefix(prefix string, fn func(key string, valueIds []ValueID) bool) {
+	d.indexOnce.Do(func() {
+		d.sortedKeys = sortedKeys(d.entries)
+	})
+	for i := sort.SearchStrings(d.sortedKeys, prefix); i < len(d.sortedKeys); i++ {
+		key := d.sortedKeys[i]
+		if !strings.HasPrefix(key, prefix) || !fn(key, d.entries[key]) {
+			return
+		}
+	}
+}
+
+// rangePrefix falls back to a full scan for dictionaries without a prefix index.
+func rangePrefix(d Dictionary, prefix string, fn func(key string, valueIds []ValueID) bool) {
+	if p, ok := d.(PrefixDictionary); ok {
+		p.RangePrefix(prefix, fn)
+		return
+	}
+	for _, key := range dictionaryKeys(d) {
+		if !strings.HasPrefix(key, prefix) {
+			continue
+		}
+		valueIds, _ := d.Lookup(key)
+		if !fn(key, valueIds) {
+			return
+		}
+	}
+}
+
+func dictionaryKeys(d Dictionary) []string {
+	keys := make([]string, 0, d.Len())
+	d.Range(func(key string, _ []ValueID) bool {
+		keys = append(keys, key)
+		return true
+	})
+	sort.Strings(keys)
+	return keys
+}
+
+type dictionarySnapshot struct {
+	Dictionary
+	version uint64
+}
+
+type ReloadableDictionary struct {
+	name    string
+	current atomic.Pointer[dictionarySnapshot]
+	mu      sync.Mutex
+}
+
+func NewReloadableDictionary(name string, entries map[string][]ValueID) *ReloadableDictionary {
+	d := &ReloadableDictionary{name: name}
+	d.current.Store(&dictionarySnapshot{NewMapDictionary(entries), 1})
+	return d
+}
+
+func (d *ReloadableDictionary) Name() string {
+	return d.name
+}
+
+func (d *ReloadableDictionary) Version() uint64 {
+	return d.current.Load().version
+}
+
+func (d *ReloadableDictionary) Replace(entries map[string][]ValueID) uint64 {
+	return d.ReplaceDictionary(NewMapDictionary(entries))
+}
+
+func (d *ReloadableDictionary) ReplaceDictionary(dict Dictionary) uint64 {
+	d.mu.Lock()
+	defer d.mu.Unlock()
+	version := d.current.Load().version + 1
+	d.current.Store(&dictionarySnapshot{dict, version})
+	return version
+}
+
+func (d *ReloadableDictionary) Lookup(key string) ([]ValueID, bool) {
+	return d.current.Load().Lookup(key)
+}
+
+func (d *ReloadableDictionary) Len() int {
+	return d.current.Load().Len()
+}
+
+func (d *ReloadableDictionary) MaxKeyLength() int {
+	return d.current.Load().MaxKeyLength()
+}
+
+func (d *ReloadableDictionary) Range(fn func(key string, valueIds []ValueID) bool) {
+	d.current.Load().Range(fn)
+}
+
+func (d *ReloadableDictionary) RangePrefix(prefix string, fn func(key string, valueIds []ValueID) bool) {
+	rangePrefix(d.current.Load().Dictionary, prefix, fn)
+}
+
+// resolveDictionary pins the snapshot of a reloadable dictionary for the whole parse,
+// so every matcher sharing it sees the same data even if it is replaced meanwhile.
+func resolveDictionary(dict Dictionary, state MatchState) Dictionary {
+	reloadable, ok := dict.(*ReloadableDictionary)
+	if !ok {
+		return dict
+	}
+	ctx := contextOf(state)
+	if ctx == nil {
+		return reloadable.current.Load().Dictionary
+	}
+	if snapshot, ok := ctx.snapshots[reloadable]; ok {
+		return snapshot.Dictionary
+	}
+	snapshot := reloadable.current.Load()
+	if ctx.snapshots == nil {
+		ctx.snapshots = make(map[*ReloadableDictionary]*dictionarySnapshot)
+	}
+	ctx.snapshots[reloadable] = snapshot
+	return snapshot.Dictionary
+}
+
+// WatchDictionaryFile polls the file in background until ctx is done and replaces the dictionary
+// contents every time the file changes. Load errors are passed to onError and keep the previous data.
+func WatchDictionaryFile(
+	ctx context.Context,
+	dict *ReloadableDictionary,
+	path string,
+	attributeId AttributeID,
+	interval time.Duration,
+	onError func(error),
+) error {
+	info, err := os.Stat(path)
+	if err != nil {
+		return err
+	}
+	lastModified, lastSize := info.ModTime(), info.Size()
+
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+
+			info, err := os.Stat(path)
+			if err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			if info.ModTime().Equal(lastModified) && info.Size() == lastSize {
+				continue
+			}
+			entries, err := LoadDictionaryFile(path, attributeId)
+			if err != nil {
+				if onError != nil {
+					onError(err)
+				}
+				continue
+			}
+			lastModified, lastSize = info.ModTime(), info.Size()
+			dict.Replace(entries)
+		}
+	}()
+	return nil
+}
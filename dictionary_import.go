@@ -0,0 +1,229 @@
+package context_free_grammar
+
+import (
+	"encoding/csv"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+)
+
+const (
+	columnKey       = "key"
+	columnAttribute = "attribute_id"
+	columnValue     = "value_id"
+	columnSynonyms  = "synonyms"
+)
+
+type Dictionaries = map[AttributeID]map[string][]ValueID
+
+type RowIssue struct {
+	Line    int
+	Key     string
+	Message string
+}
+
+func (i RowIssue) String() string {
+	if i.Key == "" {
+		return fmt.Sprintf("line %d: %s", i.Line, i.Message)
+	}
+	return fmt.Sprintf("line %d: %q: %s", i.Line, i.Key, i.Message)
+}
+
+type ImportReport struct {
+	Rows       int
+	Duplicates []RowIssue
+	Malformed  []RowIssue
+}
+
+type importOptions struct {
+	comma      rune
+	normalize  func(string) string
+	attribute  AttributeID
+	hasDefault bool
+	separators string
+}
+
+type ImportOption func(opt *importOptions)
+
+func WithComma(comma rune) ImportOption {
+	return func(opt *importOptions) {
+		opt.comma = comma
+	}
+}
+
+func WithKeyNormalizer(normalize func(string) string) ImportOption {
+	return func(opt *importOptions) {
+		opt.normalize = normalize
+	}
+}
+
+func WithDefaultAttribute(attributeId AttributeID) ImportOption {
+	return func(opt *importOptions) {
+		opt.attribute = attributeId
+		opt.hasDefault = true
+	}
+}
+
+func WithSynonymSeparators(separators string) ImportOption {
+	return func(opt *importOptions) {
+		opt.separators = separators
+	}
+}
+
+func NormalizeKey(key string) string {
+	return strings.Join(strings.Fields(strings.ToLower(key)), " ")
+}
+
+func ReadDictionariesFile(path string, opts ...ImportOption) (Dictionaries, *ImportReport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer f.Close()
+
+	if strings.EqualFold(filepath.Ext(path), ".tsv") {
+		opts = append([]ImportOption{WithComma('\t')}, opts...)
+	}
+	return ReadDictionaries(f, opts...)
+}
+
+func ReadDictionaries(r io.Reader, opts ...ImportOption) (Dictionaries, *ImportReport, error) {
+	o := &importOptions{
+		comma:      ',',
+		normalize:  NormalizeKey,
+		separators: "|;",
+	}
+	for _, opt := range opts {
+		opt(o)
+	}
+	if o.normalize == nil {
+		o.normalize = strings.TrimSpace
+	}
+
+	reader := csv.NewReader(r)
+	reader.Comma = o.comma
+	reader.FieldsPerRecord = -1
+	reader.ReuseRecord = true
+	if o.comma == '\t' {
+		reader.LazyQuotes = true
+	}
+
+	header, err := reader.Read()
+	if err != nil {
+		return nil, nil, fmt.Errorf("reading header: %w", err)
+	}
+	columns := make(map[string]int, len(header))
+	for i, name := range header {
+		if i == 0 {
+			// spreadsheet exports in UTF-8 start with a byte order mark
+			name = strings.TrimPrefix(name, "\ufeff")
+		}
+		columns[strings.ToLower(strings.TrimSpace(name))] = i
+	}
+	for _, required := range []string{columnKey, columnValue} {
+		if _, ok := columns[required]; !ok {
+			return nil, nil, fmt.Errorf("header: missing %q column", required)
+		}
+	}
+	if _, ok := columns[columnAttribute]; !ok && !o.hasDefault {
+		return nil, nil, fmt.Errorf("header: missing %q column and no default attribute", columnAttribute)
+	}
+	width := len(header)
+
+	dictionaries := make(Dictionaries)
+	report := &ImportReport{}
+	seen := make(map[AttributeID]map[string]map[ValueID]bool)
+	for {
+		record, err := reader.Read()
+		if err == io.EOF {
+			break
+		}
+		if err != nil {
+			var parseErr *csv.ParseError
+			if !errors.As(err, &parseErr) {
+				return nil, nil, err
+			}
+			report.Malformed = append(report.Malformed, RowIssue{Line: parseErr.Line, Message: parseErr.Err.Error()})
+			continue
+		}
+		report.Rows++
+		line, _ := reader.FieldPos(0)
+
+		if len(record) != width {
+			report.Malformed = append(report.Malformed, RowIssue{
+				Line:    line,
+				Message: fmt.Sprintf("expected %d columns, got %d", width, len(record)),
+			})
+			continue
+		}
+
+		key := o.normalize(record[columns[columnKey]])
+		if key == "" {
+			report.Malformed = append(report.Malformed, RowIssue{Line: line, Message: "empty key"})
+			continue
+		}
+
+		attributeId := o.attribute
+		if i, ok := columns[columnAttribute]; ok && strings.TrimSpace(record[i]) != "" {
+			attributeId, err = strconv.ParseInt(strings.TrimSpace(record[i]), 10, 64)
+			if err != nil {
+				report.Malformed = append(report.Malformed, RowIssue{
+					Line:    line,
+					Key:     key,
+					Message: fmt.Sprintf("invalid %s %q", columnAttribute, record[i]),
+				})
+				continue
+			}
+		} else if !o.hasDefault {
+			report.Malformed = append(report.Malformed, RowIssue{Line: line, Key: key, Message: "empty " + columnAttribute})
+			continue
+		}
+
+		valueId, err := strconv.ParseInt(strings.TrimSpace(record[columns[columnValue]]), 10, 64)
+		if err != nil {
+			report.Malformed = append(report.Malformed, RowIssue{
+				Line:    line,
+				Key:     key,
+				Message: fmt.Sprintf("invalid %s %q", columnValue, record[columns[columnValue]]),
+			})
+			continue
+		}
+
+		keys := []string{key}
+		if i, ok := columns[columnSynonyms]; ok {
+			synonyms := strings.FieldsFunc(record[i], func(r rune) bool {
+				return strings.ContainsRune(o.separators, r)
+			})
+			for _, synonym := range synonyms {
+				if synonym = o.normalize(synonym); synonym != "" {
+					keys = append(keys, synonym)
+				}
+			}
+		}
+
+		if dictionaries[attributeId] == nil {
+			dictionaries[attributeId] = make(map[string][]ValueID)
+			seen[attributeId] = make(map[string]map[ValueID]bool)
+		}
+		for _, k := range keys {
+			if seen[attributeId][k] == nil {
+				seen[attributeId][k] = make(map[ValueID]bool)
+			}
+			if seen[attributeId][k][valueId] {
+				report.Duplicates = append(report.Duplicates, RowIssue{
+					Line:    line,
+					Key:     k,
+					Message: fmt.Sprintf("duplicate value %d for attribute %d", valueId, attributeId),
+				})
+				continue
+			}
+			seen[attributeId][k][valueId] = true
+			dictionaries[attributeId][k] = append(dictionaries[attributeId][k], valueId)
+		}
+	}
+	return dictionaries, report, nil
+}
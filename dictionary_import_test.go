@@ -0,0 +1,116 @@
+package context_free_grammar
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDictionaries(t *testing.T) {
+	input := `key,attribute_id,value_id,synonyms
+Однокомнатная,1,1,1к|  Однушка
+двухкомнатная,1,2,2к;двушка
+2 или 3 комнатная,1,2,
+2 или 3 комнатная,1,3,
+Москва,2,10,мск
+москва,2,10,
+студия,1,x,
+,1,5,
+питер,2
+`
+	dictionaries, report, err := ReadDictionaries(strings.NewReader(input))
+	require.NoError(t, err)
+	require.Equal(t, Dictionaries{
+		1: {
+			"однокомнатная":     {1},
+			"1к":                {1},
+			"однушка":           {1},
+			"двухкомнатная":     {2},
+			"2к":                {2},
+			"двушка":            {2},
+			"2 или 3 комнатная": {2, 3},
+		},
+		2: {
+			"москва": {10},
+			"мск":    {10},
+		},
+	}, dictionaries)
+
+	require.Equal(t, 9, report.Rows)
+	require.Equal(t, []RowIssue{
+		{Line: 7, Key: "москва", Message: "duplicate value 10 for attribute 2"},
+	}, report.Duplicates)
+	require.Equal(t, []RowIssue{
+		{Line: 8, Key: "студия", Message: `invalid value_id "x"`},
+		{Line: 9, Message: "empty key"},
+		{Line: 10, Message: "expected 4 columns, got 2"},
+	}, report.Malformed)
+}
+
+func TestReadDictionaries_HeaderErrors(t *testing.T) {
+	_, _, err := ReadDictionaries(strings.NewReader("key,value_id\nлорем,1\n"))
+	require.EqualError(t, err, `header: missing "attribute_id" column and no default attribute`)
+
+	_, _, err = ReadDictionaries(strings.NewReader("key,attribute_id\nлорем,1\n"))
+	require.EqualError(t, err, `header: missing "value_id" column`)
+}
+
+func TestReadDictionaries_ByteOrderMark(t *testing.T) {
+	dictionaries, report, err := ReadDictionaries(strings.NewReader("\ufeffkey,attribute_id,value_id\nлорем,1,5\n"))
+	require.NoError(t, err)
+	require.Empty(t, report.Malformed)
+	require.Equal(t, Dictionaries{1: {"лорем": {5}}}, dictionaries)
+}
+
+func TestLoadDictionaryFile_Errors(t *testing.T) {
+	dir := t.TempDir()
+	write := func(content string) string {
+		path := filepath.Join(dir, "rooms.csv")
+		require.NoError(t, os.WriteFile(path, []byte(content), 0o644))
+		return path
+	}
+
+	_, err := LoadDictionaryFile(write("key,attribute_id,value_id\nстудия,1,0\nмосква,2,10\n"), 1)
+	require.ErrorContains(t, err, "has entries for attribute 2, expected 1 only")
+
+	_, err = LoadDictionaryFile(write("key,value_id,synonyms\nдвушка,2,\n2к,2,двушка\n"), 1)
+	require.ErrorContains(t, err, `line 3: "двушка": duplicate value 2 for attribute 1`)
+
+	dict, err := LoadDictionaryFile(write("key,attribute_id,value_id\nстудия,,0\n2к,1,2\n"), 1)
+	require.NoError(t, err)
+	require.Equal(t, map[string][]ValueID{"студия": {0}, "2к": {2}}, dict)
+}
+
+func TestReadDictionariesFile_TSVInGrammarConfig(t *testing.T) {
+	dir := t.TempDir()
+	require.NoError(t, os.WriteFile(
+		filepath.Join(dir, "rooms.tsv"),
+		[]byte("key\tvalue_id\tsynonyms\n\"студия\"\t0\tстудию\n2к\t2\tдвушка\n"),
+		0o644,
+	))
+
+	dictionaries, report, err := ReadDictionariesFile(filepath.Join(dir, "rooms.tsv"), WithDefaultAttribute(1))
+	require.NoError(t, err)
+	require.Empty(t, report.Malformed)
+	require.Equal(t, map[string][]ValueID{
+		"студия": {0},
+		"студию": {0},
+		"2к":     {2},
+		"двушка": {2},
+	}, dictionaries[1])
+
+	root, err := LoadGrammar(strings.NewReader(`
+root:
+  type: fullText
+  children:
+    - {type: dict, attribute: 1, dictionaryFile: rooms.tsv}
+    - {type: word, word: снять}
+`), FormatYAML, dir)
+	require.NoError(t, err)
+	res := root.Match(NewInitialState(getTokens("снять двушка")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{1: {2}})
+}
@@ -0,0 +1,73 @@
+package context_free_grammar
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/require"
+)
+
+type matcherFunc func(state MatchState) MatchState
+
+func (f matcherFunc) Match(state MatchState) MatchState {
+	return f(state)
+}
+
+func TestReloadableDictionary_SnapshotPerParse(t *testing.T) {
+	rooms := NewReloadableDictionary("rooms", map[string][]ValueID{"2к": {2}})
+	reload := matcherFunc(func(state MatchState) MatchState {
+		rooms.Replace(map[string][]ValueID{"3к": {3}})
+		return NewMatchStateFrom(state, true, state.RemainingTokens()[1:], nil, state.Memory())
+	})
+	root := NewSequenceMatcher([]Matcher{
+		NewDictMatcherFrom(rooms, 1),
+		reload,
+		NewAnyOrderDictMatcherFrom(rooms, 1),
+	})
+
+	res := root.Match(NewInitialState(getTokens("2к и 2к")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{1: {2, 2}})
+	require.Equal(t, map[string]uint64{"rooms": 1}, res.DictionaryVersions())
+	require.Equal(t, uint64(2), rooms.Version())
+
+	res = NewDictMatcherFrom(rooms, 1).Match(NewInitialState(getTokens("3к")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{1: {3}})
+	require.Equal(t, map[string]uint64{"rooms": 2}, res.DictionaryVersions())
+
+	res = NewDictMatcherFrom(rooms, 1).Match(NewInitialState(getTokens("2к")))
+	testNegativeParse(t, res)
+}
+
+func TestWatchDictionaryFile(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "rooms.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"2к": [2]}`), 0o644))
+
+	entries, err := LoadDictionaryFile(path, 1)
+	require.NoError(t, err)
+	rooms := NewReloadableDictionary("rooms", entries)
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	var loadErrors atomic.Int32
+	require.NoError(t, WatchDictionaryFile(ctx, rooms, path, 1, time.Millisecond, func(error) {
+		loadErrors.Add(1)
+	}))
+
+	require.NoError(t, os.WriteFile(path, []byte(`{"2к": [2], "3к": [3]}`), 0o644))
+	modified := time.Now().Add(time.Second)
+	require.NoError(t, os.Chtimes(path, modified, modified))
+
+	require.Eventually(t, func() bool {
+		return rooms.Version() == 2
+	}, time.Second, time.Millisecond)
+	valueIds, ok := rooms.Lookup("3к")
+	require.True(t, ok)
+	require.Equal(t, []ValueID{3}, valueIds)
+	require.Zero(t, loadErrors.Load())
+}
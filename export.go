@@ -0,0 +1,402 @@
+package context_free_grammar
+
+import (
+	"bufio"
+	"fmt"
+	"html"
+	"io"
+	"reflect"
+	"strconv"
+	"strings"
+	"unicode/utf8"
+)
+
+type dotWriter struct {
+	w     *bufio.Writer
+	ids   map[Matcher]string
+	count int
+}
+
+func WriteDOT(w io.Writer, root Matcher) error {
+	d := &dotWriter{
+		w:   bufio.NewWriter(w),
+		ids: make(map[Matcher]string),
+	}
+	d.w.WriteString("digraph grammar {\n")
+	d.w.WriteString("\tnode [fontname=\"Helvetica\"];\n")
+	d.node(root)
+	d.w.WriteString("}\n")
+	return d.w.Flush()
+}
+
+func (d *dotWriter) node(m Matcher) string {
+	comparable := reflect.TypeOf(m).Comparable()
+	if comparable {
+		if id, ok := d.ids[m]; ok {
+			return id
+		}
+	}
+	id := "n" + strconv.Itoa(d.count)
+	d.count++
+	if comparable {
+		d.ids[m] = id
+	}
+
+	shape, label := dotNodeStyle(m)
+	fmt.Fprintf(d.w, "\t%s [shape=%s, label=%s];\n", id, shape, strconv.Quote(label))
+
+	c, ok := m.(Composite)
+	if !ok {
+		return id
+	}
+	optionalFrom := -1
+	if p, ok := m.(*permutationMatcher); ok {
+		optionalFrom = len(p.required)
+	}
+	_, ordered := m.(*sequenceMatcher)
+	for i, child := range c.Children() {
+		childId := d.node(child)
+		var attrs []string
+		if ordered {
+			attrs = append(attrs, "label="+strconv.Quote(strconv.Itoa(i+1)))
+		}
+		if optionalFrom >= 0 && i >= optionalFrom {
+			attrs = append(attrs, "style=dashed")
+		}
+		if len(attrs) > 0 {
+			fmt.Fprintf(d.w, "\t%s -> %s [%s];\n", id, childId, strings.Join(attrs, ", "))
+		} else {
+			fmt.Fprintf(d.w, "\t%s -> %s;\n", id, childId)
+		}
+	}
+	return id
+}
+
+func dotNodeStyle(m Matcher) (shape, label string) {
+	name := ""
+	if n, ok := m.(Named); ok && n.Name() != "" {
+		name = n.Name() + "\n"
+	}
+	switch m := m.(type) {
+	case *allowedWordMatcher:
+		return "plaintext", name + m.expected()
+	case *allowedWordsMatcher:
+		return "note", name + "words\n" + m.expected()
+	case *dictMatcher:
+		return "cylinder", name + fmt.Sprintf("dictionary\nattribute=%d\nsize=%d", m.attributeId, m.dict.Len())
+	case *anyOrderDictMatcher:
+		return "cylinder", name + fmt.Sprintf("anyOrder dictionary\nattribute=%d\nsize=%d", m.attributeId, m.dict.Len())
+	case *sequenceMatcher:
+		return "box", name + "sequence"
+	case *fullTextMatcher:
+		return "doubleoctagon", name + "fullText"
+	case *oneOfMatcher:
+		return "diamond", name + "oneOf"
+	case *onceMatcher:
+		return "oval", name + "once"
+	case *tryAllMatcher:
+		return "trapezium", name + "tryAll"
+	case *permutationMatcher:
+		return "hexagon", name + "permutation"
+	}
+	return "box", Label(m)
+}
+
+const (
+	railroadCharWidth = 7.5
+	railroadBoxHeight = 22
+	railroadGap       = 10
+	railroadVGap      = 8
+	railroadLabelH    = 16
+)
+
+type railroadElement interface {
+	width() float64
+	up() float64
+	down() float64
+	render(b *strings.Builder, x, y float64)
+}
+
+func WriteRailroadSVG(w io.Writer, root Matcher) error {
+	diagram := &railroadSequence{items: []railroadElement{railroadDiagram(root)}}
+	margin := 20.0
+	width := diagram.width() + 2*margin
+	height := diagram.up() + diagram.down() + 2*margin
+	y := margin + diagram.up()
+
+	var b strings.Builder
+	fmt.Fprintf(
+		&b,
+		`<svg xmlns="http://www.w3.org/2000/svg" width="%.0f" height="%.0f" viewBox="0 0 %.0f %.0f">`+"\n",
+		width, height, width, height,
+	)
+	b.WriteString("<style>path{fill:none;stroke:#333;stroke-width:1.5}rect{fill:#fff;stroke:#333;stroke-width:1.5}" +
+		"rect.group{fill:none;stroke:#999;stroke-dasharray:4 3}text{font:12px monospace;fill:#000}" +
+		"text.label{fill:#666;font-size:10px}circle{fill:#333}</style>\n")
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="4"/>`+"\n", margin, y)
+	diagram.render(&b, margin, y)
+	fmt.Fprintf(&b, `<circle cx="%.1f" cy="%.1f" r="4"/>`+"\n", margin+diagram.width(), y)
+	b.WriteString("</svg>\n")
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func railroadDiagram(m Matcher) railroadElement {
+	name := ""
+	if n, ok := m.(Named); ok {
+		name = n.Name()
+	}
+	var element railroadElement
+	switch m := m.(type) {
+	case *allowedWordMatcher:
+		return &railroadBox{text: Label(m), rounded: true}
+	case *allowedWordsMatcher:
+		if len(m.words) > maxDescribedWords {
+			return &railroadBox{text: Label(m)}
+		}
+		words := strings.Split(m.expected(), ", ")
+		items := make([]railroadElement, 0, len(words))
+		for _, word := range words {
+			items = append(items, &railroadBox{text: word, rounded: true})
+		}
+		element = &railroadChoice{items: items}
+	case *dictMatcher:
+		return &railroadBox{text: railroadPrefix(name) + fmt.Sprintf("dictionary attr=%d size=%d", m.attributeId, m.dict.Len())}
+	case *anyOrderDictMatcher:
+		return &railroadBox{text: railroadPrefix(name) + fmt.Sprintf("anyOrder dictionary attr=%d size=%d", m.attributeId, m.dict.Len())}
+	case *sequenceMatcher:
+		element = &railroadSequence{items: railroadDiagrams(m.words)}
+	case *oneOfMatcher:
+		element = &railroadChoice{items: railroadDiagrams(m.words)}
+	case *fullTextMatcher:
+		element = &railroadLoop{item: &railroadChoice{items: railroadDiagrams(m.nodes)}}
+	case *onceMatcher:
+		return &railroadGroup{label: railroadPrefix(name) + "once", item: railroadDiagram(m.matcher)}
+	case *tryAllMatcher:
+		items := make([]railroadElement, 0, len(m.nodes))
+		for _, node := range m.nodes {
+			items = append(items, railroadOptional(railroadDiagram(node)))
+		}
+		return &railroadGroup{label: railroadPrefix(name) + "tryAll", item: &railroadSequence{items: items}}
+	case *permutationMatcher:
+		items := railroadDiagrams(m.required)
+		for _, node := range m.optional {
+			items = append(items, railroadOptional(railroadDiagram(node)))
+		}
+		return &railroadGroup{label: railroadPrefix(name) + "any order", item: &railroadChoice{items: items}}
+	default:
+		if c, ok := m.(Composite); ok {
+			return &railroadGroup{label: Label(m), item: &railroadSequence{items: railroadDiagrams(c.Children())}}
+		}
+		return &railroadBox{text: Label(m)}
+	}
+	if name != "" {
+		return &railroadGroup{label: name, item: element}
+	}
+	return element
+}
+
+func railroadDiagrams(matchers []Matcher) []railroadElement {
+	items := make([]railroadElement, 0, len(matchers))
+	for _, m := range matchers {
+		items = append(items, railroadDiagram(m))
+	}
+	return items
+}
+
+func railroadPrefix(name string) string {
+	if name == "" {
+		return ""
+	}
+	return name + ": "
+}
+
+func railroadOptional(item railroadElement) railroadElement {
+	return &railroadChoice{items: []railroadElement{&railroadSequence{}, item}}
+}
+
+type railroadBox struct {
+	text    string
+	rounded bool
+}
+
+func (r *railroadBox) width() float64 {
+	return float64(utf8.RuneCountInString(r.text))*railroadCharWidth + 2*railroadGap
+}
+
+func (r *railroadBox) up() float64 {
+	return railroadBoxHeight / 2
+}
+
+func (r *railroadBox) down() float64 {
+	return railroadBoxHeight / 2
+}
+
+func (r *railroadBox) render(b *strings.Builder, x, y float64) {
+	radius := 0
+	if r.rounded {
+		radius = railroadBoxHeight / 2
+	}
+	fmt.Fprintf(
+		b,
+		`<rect x="%.1f" y="%.1f" width="%.1f" height="%d" rx="%d"/>`+"\n",
+		x, y-r.up(), r.width(), railroadBoxHeight, radius,
+	)
+	fmt.Fprintf(
+		b,
+		`<text x="%.1f" y="%.1f" text-anchor="middle">%s</text>`+"\n",
+		x+r.width()/2, y+4, html.EscapeString(r.text),
+	)
+}
+
+type railroadSequence struct {
+	items []railroadElement
+}
+
+func (r *railroadSequence) width() float64 {
+	width := railroadGap * float64(len(r.items)+1)
+	for _, item := range r.items {
+		width += item.width()
+	}
+	return width
+}
+
+func (r *railroadSequence) up() float64 {
+	up := 0.0
+	for _, item := range r.items {
+		up = max(up, item.up())
+	}
+	return up
+}
+
+func (r *railroadSequence) down() float64 {
+	down := 0.0
+	for _, item := range r.items {
+		down = max(down, item.down())
+	}
+	return down
+}
+
+func (r *railroadSequence) render(b *strings.Builder, x, y float64) {
+	fmt.Fprintf(b, `<path d="M%.1f %.1fh%d"/>`+"\n", x, y, railroadGap)
+	x += railroadGap
+	for _, item := range r.items {
+		item.render(b, x, y)
+		x += item.width()
+		fmt.Fprintf(b, `<path d="M%.1f %.1fh%d"/>`+"\n", x, y, railroadGap)
+		x += railroadGap
+	}
+}
+
+type railroadChoice struct {
+	items []railroadElement
+}
+
+func (r *railroadChoice) innerWidth() float64 {
+	width := 0.0
+	for _, item := range r.items {
+		width = max(width, item.width())
+	}
+	return width
+}
+
+func (r *railroadChoice) width() float64 {
+	return r.innerWidth() + 4*railroadGap
+}
+
+func (r *railroadChoice) up() float64 {
+	if len(r.items) == 0 {
+		return 0
+	}
+	return r.items[0].up()
+}
+
+func (r *railroadChoice) down() float64 {
+	if len(r.items) == 0 {
+		return 0
+	}
+	down := r.items[0].down()
+	for _, item := range r.items[1:] {
+		down += railroadVGap + item.up() + item.down()
+	}
+	return down
+}
+
+func (r *railroadChoice) render(b *strings.Builder, x, y float64) {
+	inner := r.innerWidth()
+	itemY := y
+	for i, item := range r.items {
+		if i > 0 {
+			itemY += r.items[i-1].down() + railroadVGap + item.up()
+		}
+		fmt.Fprintf(b, `<path d="M%.1f %.1fh%dV%.1fh%d"/>`+"\n", x, y, railroadGap, itemY, railroadGap)
+		item.render(b, x+2*railroadGap, itemY)
+		fmt.Fprintf(
+			b,
+			`<path d="M%.1f %.1fH%.1fV%.1fh%d"/>`+"\n",
+			x+2*railroadGap+item.width(), itemY, x+3*railroadGap+inner, y, railroadGap,
+		)
+	}
+}
+
+type railroadLoop struct {
+	item railroadElement
+}
+
+func (r *railroadLoop) width() float64 {
+	return r.item.width() + 2*railroadGap
+}
+
+func (r *railroadLoop) up() float64 {
+	return r.item.up()
+}
+
+func (r *railroadLoop) down() float64 {
+	return r.item.down() + railroadVGap
+}
+
+func (r *railroadLoop) render(b *strings.Builder, x, y float64) {
+	right := x + railroadGap + r.item.width()
+	fmt.Fprintf(b, `<path d="M%.1f %.1fh%d"/>`+"\n", x, y, railroadGap)
+	r.item.render(b, x+railroadGap, y)
+	fmt.Fprintf(b, `<path d="M%.1f %.1fh%d"/>`+"\n", right, y, railroadGap)
+	fmt.Fprintf(
+		b,
+		`<path d="M%.1f %.1fh%dV%.1fH%.1fV%.1fh%d"/>`+"\n",
+		right, y, railroadGap/2, y+r.down(), x+railroadGap/2, y, railroadGap/2,
+	)
+}
+
+type railroadGroup struct {
+	label string
+	item  railroadElement
+}
+
+func (r *railroadGroup) width() float64 {
+	return max(r.item.width(), float64(utf8.RuneCountInString(r.label))*railroadCharWidth) + 2*railroadGap
+}
+
+func (r *railroadGroup) up() float64 {
+	return r.item.up() + railroadLabelH
+}
+
+func (r *railroadGroup) down() float64 {
+	return r.item.down() + railroadVGap
+}
+
+func (r *railroadGroup) render(b *strings.Builder, x, y float64) {
+	fmt.Fprintf(
+		b,
+		`<rect class="group" x="%.1f" y="%.1f" width="%.1f" height="%.1f"/>`+"\n",
+		x, y-r.up(), r.width(), r.up()+r.down(),
+	)
+	fmt.Fprintf(
+		b,
+		`<text class="label" x="%.1f" y="%.1f">%s</text>`+"\n",
+		x+4, y-r.up()+12, html.EscapeString(r.label),
+	)
+	fmt.Fprintf(b, `<path d="M%.1f %.1fh%d"/>`+"\n", x, y, railroadGap)
+	r.item.render(b, x+railroadGap, y)
+	fmt.Fprintf(b, `<path d="M%.1f %.1fH%.1f"/>`+"\n", x+railroadGap+r.item.width(), y, x+r.width())
+}
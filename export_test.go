@@ -0,0 +1,110 @@
+package context_free_grammar
+
+import (
+	"bytes"
+	"encoding/xml"
+	"io"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestWriteDOT(t *testing.T) {
+	lorem := NewAllowedWordMatcher("lorem")
+	root := NewFullTextMatcher([]Matcher{
+		NewOnceMatcher(NewOneOfMatcher([]Matcher{
+			NewSequenceMatcher([]Matcher{lorem, NewAllowedWordMatcher("ipsum")}),
+			lorem,
+		})),
+		NewDictMatcher(map[string][]ValueID{"abra": {1}, "cadabra": {2}}, 100500, WithName("magic")),
+		NewPermutationMatcher(
+			[]Matcher{NewAnyOrderDictMatcher(map[string][]ValueID{"2к": {2}}, 1)},
+			[]Matcher{NewTryAllMatcher([]Matcher{NewAllowedWordsMatcher([]string{"matcher"})})},
+		),
+	}, WithName("root"))
+
+	var b bytes.Buffer
+	require.NoError(t, WriteDOT(&b, root))
+
+	expected := `digraph grammar {
+	node [fontname="Helvetica"];
+	n0 [shape=doubleoctagon, label="root\nfullText"];
+	n1 [shape=oval, label="once"];
+	n2 [shape=diamond, label="oneOf"];
+	n3 [shape=box, label="sequence"];
+	n4 [shape=plaintext, label="\"lorem\""];
+	n3 -> n4 [label="1"];
+	n5 [shape=plaintext, label="\"ipsum\""];
+	n3 -> n5 [label="2"];
+	n2 -> n3;
+	n2 -> n4;
+	n1 -> n2;
+	n0 -> n1;
+	n6 [shape=cylinder, label="magic\ndictionary\nattribute=100500\nsize=2"];
+	n0 -> n6;
+	n7 [shape=hexagon, label="permutation"];
+	n8 [shape=cylinder, label="anyOrder dictionary\nattribute=1\nsize=1"];
+	n7 -> n8;
+	n9 [shape=trapezium, label="tryAll"];
+	n10 [shape=note, label="words\n\"matcher\""];
+	n9 -> n10;
+	n7 -> n9 [style=dashed];
+	n0 -> n7;
+}
+`
+	require.Equal(t, expected, b.String())
+}
+
+func TestWriteRailroadSVG(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewAllowedWordMatcher("allowed"),
+		NewOnceMatcher(NewOneOfMatcher([]Matcher{
+			NewSequenceMatcher([]Matcher{
+				NewAllowedWordMatcher("lorem"),
+				NewAllowedWordMatcher("ipsum"),
+			}),
+			NewAllowedWordMatcher("lorem"),
+		})),
+		NewDictMatcher(map[string][]ValueID{"abra": {1}}, 100500),
+		NewAllowedWordsMatcher([]string{"awesome", "matcher"}),
+		NewTryAllMatcher([]Matcher{NewAnyOrderDictMatcher(map[string][]ValueID{"снять": {1}}, 1)}),
+	})
+
+	var b bytes.Buffer
+	require.NoError(t, WriteRailroadSVG(&b, root))
+
+	var texts []string
+	decoder := xml.NewDecoder(&b)
+	inText := false
+	for {
+		token, err := decoder.Token()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		switch token := token.(type) {
+		case xml.StartElement:
+			inText = token.Name.Local == "text"
+		case xml.CharData:
+			if inText {
+				texts = append(texts, strings.TrimSpace(string(token)))
+			}
+		case xml.EndElement:
+			inText = false
+		}
+	}
+
+	require.Equal(t, []string{
+		`"allowed"`,
+		"once",
+		`"lorem"`,
+		`"ipsum"`,
+		`"lorem"`,
+		"dictionary attr=100500 size=1",
+		`"awesome"`,
+		`"matcher"`,
+		"tryAll",
+		"anyOrder dictionary attr=1 size=1",
+	}, texts)
+}
@@ -0,0 +1,312 @@
+package context_free_grammar
+
+import (
+	"maps"
+	"math/rand"
+	"slices"
+	"strings"
+)
+
+const (
+	defaultGenerateDepth = 16
+	defaultGenerateCount = 100
+	maxSampleAttempts    = 10
+)
+
+type Sample struct {
+	Tokens []string
+	// Memory holds the values root extracts from Tokens, which may differ from the generating path
+	// when an earlier alternative of a oneOf or full text matcher shadows it.
+	Memory AttrValues
+}
+
+type generateOptions struct {
+	depth  int
+	count  int
+	random *rand.Rand
+}
+
+type GenerateOption func(opt *generateOptions)
+
+// GenerateDepth bounds the matcher nesting depth, each repetition of a full text matcher counts as a level too.
+func GenerateDepth(depth int) GenerateOption {
+	return func(opt *generateOptions) {
+		opt.depth = depth
+	}
+}
+
+func GenerateCount(count int) GenerateOption {
+	return func(opt *generateOptions) {
+		opt.count = count
+	}
+}
+
+// RandomSeed samples sentences randomly instead of enumerating them in grammar order.
+func RandomSeed(seed int64) GenerateOption {
+	return func(opt *generateOptions) {
+		opt.random = rand.New(rand.NewSource(seed))
+	}
+}
+
+// Generate runs the grammar in reverse and returns distinct token sequences accepted by root.
+// Capture, regexp and custom matchers produce nothing, so branches requiring them are dropped.
+func Generate(root Matcher, opts ...GenerateOption) []Sample {
+	o := &generateOptions{depth: defaultGenerateDepth, count: defaultGenerateCount}
+	for _, opt := range opts {
+		opt(o)
+	}
+	g := &generator{generateOptions: *o, keys: make(map[Dictionary][]string)}
+
+	var res []Sample
+	seen := make(map[string]bool)
+	accept := func(sentence sentence) bool {
+		text := strings.Join(sentence.tokens, " ")
+		if seen[text] {
+			return false
+		}
+		state := root.Match(NewInitialState(sentence.tokens))
+		if !state.HasMatch() || len(state.RemainingTokens()) > 0 {
+			return false
+		}
+		seen[text] = true
+		res = append(res, Sample{sentence.tokens, state.Memory().GetStorage()})
+		return true
+	}
+
+	if g.random == nil {
+		g.generate(root, sentence{}, 0, func(s sentence) bool {
+			accept(s)
+			return len(res) < g.count
+		})
+		return res
+	}
+	for attempt := 0; attempt < g.count*maxSampleAttempts && len(res) < g.count; attempt++ {
+		g.generate(root, sentence{}, 0, func(s sentence) bool {
+			accept(s)
+			return false
+		})
+	}
+	return res
+}
+
+type sentence struct {
+	tokens   []string
+	memory   AttrValues
+	usedOnce map[*onceMatcher]bool
+}
+
+// extend returns a copy of the sentence, the receiver is shared by sibling branches.
+func (s sentence) extend(tokens []string, attributeId AttributeID, valueIds []ValueID) sentence {
+	res := s
+	res.tokens = append(slices.Clip(s.tokens), tokens...)
+	if len(valueIds) > 0 {
+		res.memory = maps.Clone(s.memory)
+		if res.memory == nil {
+			res.memory = make(AttrValues)
+		}
+		res.memory[attributeId] = append(slices.Clip(s.memory[attributeId]), valueIds...)
+	}
+	return res
+}
+
+type generator struct {
+	generateOptions
+	keys map[Dictionary][]string
+}
+
+// order returns the order to try n alternatives in.
+func (g *generator) order(n int) []int {
+	n = max(n, 0)
+	if g.random != nil {
+		return g.random.Perm(n)
+	}
+	res := make([]int, n)
+	for i := range res {
+		res[i] = i
+	}
+	return res
+}
+
+// sample returns the keys to try out of n: a single random one in random mode,
+// where a permutation would cost O(n) on every visit of a large dictionary.
+func (g *generator) sample(n int) []int {
+	if g.random != nil {
+		if n <= 0 {
+			return nil
+		}
+		return []int{g.random.Intn(n)}
+	}
+	return g.order(n)
+}
+
+func (g *generator) dictionaryKeys(dict Dictionary) []string {
+	keys, ok := g.keys[dict]
+	if !ok {
+		keys = dictionaryKeys(dict)
+		g.keys[dict] = keys
+	}
+	return keys
+}
+
+// generate passes every sentence the matcher can append to s to k until k returns false.
+// It returns false once generation is stopped.
+func (g *generator) generate(m Matcher, s sentence, depth int, k func(sentence) bool) bool {
+	if depth >= g.depth {
+		return true
+	}
+	switch m := m.(type) {
+	case *allowedWordMatcher:
+		return k(s.extend([]string{m.word}, 0, nil))
+	case *allowedWordsMatcher:
+		words := sortedKeys(m.words)
+		for _, i := range g.sample(len(words)) {
+			if !k(s.extend(strings.Split(words[i], " "), 0, nil)) {
+				return false
+			}
+		}
+	case *dictMatcher:
+		return g.dictionary(resolveDictionary(m.dict, nil), m.attributeId, s, k)
+	case *anyOrderDictMatcher:
+		return g.dictionary(resolveDictionary(m.dict, nil), m.attributeId, s, k)
+	case *sequenceMatcher:
+		return g.sequence(m.words, s, depth, k)
+	case *oneOfMatcher:
+		for _, i := range g.order(len(m.words)) {
+			if !g.generate(m.words[i], s, depth+1, k) {
+				return false
+			}
+		}
+	case *fullTextMatcher:
+		for _, n := range g.order(g.depth - depth - 1) {
+			if !g.repeat(m.nodes, n+1, s, depth+1, k) {
+				return false
+			}
+		}
+	case *tryAllMatcher:
+		return g.tryAll(m.nodes, false, s, depth, k)
+	case *permutationMatcher:
+		nodes := make([]Matcher, 0, len(m.required)+len(m.optional))
+		nodes = append(append(nodes, m.required...), m.optional...)
+		return g.permute(nodes, len(m.required), make([]bool, len(nodes)), s, depth, k)
+	case *onceMatcher:
+		if s.usedOnce[m] {
+			return true
+		}
+		return g.generate(m.matcher, s, depth+1, func(s sentence) bool {
+			s.usedOnce = maps.Clone(s.usedOnce)
+			if s.usedOnce == nil {
+				s.usedOnce = make(map[*onceMatcher]bool)
+			}
+			s.usedOnce[m] = true
+			return k(s)
+		})
+	case *guardMatcher:
+		if !m.condition(s.memory) {
+			return true
+		}
+		return g.generate(m.matcher, s, depth+1, k)
+	case *actionMatcher:
+		start := len(s.tokens)
+		return g.generate(m.matcher, s, depth+1, func(res sentence) bool {
+			values, err := m.action(ActionContext{
+				Tokens: res.tokens[start:],
+				Start:  start,
+				End:    len(res.tokens),
+				Memory: maps.Clone(res.memory),
+			})
+			if err != nil {
+				return true
+			}
+			for _, attributeId := range sortedKeys(values) {
+				res = res.extend(nil, attributeId, values[attributeId])
+			}
+			return k(res)
+		})
+	}
+	return true
+}
+
+func (g *generator) dictionary(dict Dictionary, attributeId AttributeID, s sentence, k func(sentence) bool) bool {
+	keys := g.dictionaryKeys(dict)
+	for _, i := range g.sample(len(keys)) {
+		valueIds, _ := dict.Lookup(keys[i])
+		if !k(s.extend(strings.Split(keys[i], " "), attributeId, valueIds)) {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *generator) sequence(nodes []Matcher, s sentence, depth int, k func(sentence) bool) bool {
+	if len(nodes) == 0 {
+		return k(s)
+	}
+	return g.generate(nodes[0], s, depth+1, func(s sentence) bool {
+		return g.sequence(nodes[1:], s, depth, k)
+	})
+}
+
+// repeat appends exactly n matches of any of the nodes.
+func (g *generator) repeat(nodes []Matcher, n int, s sentence, depth int, k func(sentence) bool) bool {
+	if n == 0 {
+		return k(s)
+	}
+	for _, i := range g.order(len(nodes)) {
+		ok := g.generate(nodes[i], s, depth, func(s sentence) bool {
+			return g.repeat(nodes, n-1, s, depth+1, k)
+		})
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *generator) tryAll(nodes []Matcher, matched bool, s sentence, depth int, k func(sentence) bool) bool {
+	if len(nodes) == 0 {
+		return !matched || k(s)
+	}
+	for _, include := range g.order(2) {
+		var ok bool
+		if include == 0 {
+			ok = g.generate(nodes[0], s, depth+1, func(s sentence) bool {
+				return g.tryAll(nodes[1:], true, s, depth, k)
+			})
+		} else {
+			ok = g.tryAll(nodes[1:], matched, s, depth, k)
+		}
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
+
+func (g *generator) permute(nodes []Matcher, required int, used []bool, s sentence, depth int, k func(sentence) bool) bool {
+	matchedAny, complete := false, true
+	for i := range nodes {
+		matchedAny = matchedAny || used[i]
+		complete = complete && (used[i] || i >= required)
+	}
+	// the first alternative stops the permutation here
+	for _, i := range g.order(len(nodes) + 1) {
+		if i == 0 {
+			if matchedAny && complete && !k(s) {
+				return false
+			}
+			continue
+		}
+		if i--; used[i] {
+			continue
+		}
+		used[i] = true
+		ok := g.generate(nodes[i], s, depth+1, func(s sentence) bool {
+			return g.permute(nodes, required, used, s, depth, k)
+		})
+		used[i] = false
+		if !ok {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,87 @@
+package context_free_grammar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerate(t *testing.T) {
+	const city AttributeID = 2
+	root := NewFullTextMatcher([]Matcher{
+		NewOnceMatcher(NewAllowedWordsMatcher([]string{"сниму", "куплю"})),
+		NewOnceMatcher(NewDictMatcher(map[string][]ValueID{"москва": {77}, "санкт петербург": {78}}, city)),
+	})
+
+	samples := Generate(root)
+	require.Len(t, samples, 12)
+	require.Equal(t, []Sample{
+		{Tokens: []string{"куплю"}, Memory: AttrValues{}},
+		{Tokens: []string{"сниму"}, Memory: AttrValues{}},
+		{Tokens: []string{"москва"}, Memory: AttrValues{city: {77}}},
+		{Tokens: []string{"санкт", "петербург"}, Memory: AttrValues{city: {78}}},
+		{Tokens: []string{"куплю", "москва"}, Memory: AttrValues{city: {77}}},
+	}, samples[:5])
+	require.Len(t, Generate(root, GenerateCount(3)), 3)
+	require.Len(t, Generate(root, GenerateDepth(3)), 4)
+
+	samples = Generate(root, RandomSeed(7), GenerateCount(5))
+	require.Len(t, samples, 5)
+	require.Equal(t, samples, Generate(root, RandomSeed(7), GenerateCount(5)))
+	seen := make(map[string]bool)
+	for _, sample := range samples {
+		text := strings.Join(sample.Tokens, " ")
+		require.False(t, seen[text], text)
+		seen[text] = true
+
+		res := root.Match(NewInitialState(sample.Tokens))
+		testPositiveParse(t, res)
+		testDictParserResult(t, res, sample.Memory)
+	}
+}
+
+func TestGenerate_Combinators(t *testing.T) {
+	texts := func(samples []Sample) []string {
+		var res []string
+		for _, sample := range samples {
+			res = append(res, strings.Join(sample.Tokens, " "))
+		}
+		return res
+	}
+
+	permutation := NewPermutationMatcher([]Matcher{NewAllowedWordMatcher("a")}, []Matcher{NewAllowedWordMatcher("b")})
+	require.Equal(t, []string{"a", "a b", "b a"}, texts(Generate(permutation)))
+
+	tryAll := NewTryAllMatcher([]Matcher{NewAllowedWordMatcher("a"), NewAllowedWordMatcher("b")})
+	require.Equal(t, []string{"a b", "a", "b"}, texts(Generate(tryAll)))
+
+	action := NewActionMatcher(
+		NewAnyOrderDictMatcher(map[string][]ValueID{"2 комнаты": {2}}, 1),
+		func(ctx ActionContext) (AttrValues, error) {
+			return AttrValues{2: {int64(len(ctx.Tokens))}}, nil
+		},
+	)
+	require.Equal(t, []Sample{
+		{Tokens: []string{"2", "комнаты"}, Memory: AttrValues{1: {2}, 2: {2}}},
+	}, Generate(NewSequenceMatcher([]Matcher{action})))
+
+	guarded := NewSequenceMatcher([]Matcher{
+		NewOneOfMatcher([]Matcher{
+			NewDictMatcher(map[string][]ValueID{"москва": {77}}, 2),
+			NewAllowedWordMatcher("где"),
+		}),
+		NewGuardMatcher(Has(2), NewAllowedWordMatcher("метро")),
+	})
+	require.Equal(t, []string{"москва метро"}, texts(Generate(guarded)))
+	require.Empty(t, Generate(NewCaptureMatcher(1)))
+
+	shadowed := NewFullTextMatcher([]Matcher{
+		NewAllowedWordMatcher("a"),
+		NewAllowedWordMatcher("b"),
+		NewDictMatcher(map[string][]ValueID{"a b": {1}}, 1),
+	})
+	for _, sample := range Generate(shadowed, GenerateDepth(3)) {
+		require.Equal(t, AttrValues{}, sample.Memory, sample.Tokens)
+	}
+}
@@ -1,6 +1,10 @@
 package context_free_grammar
 
 import (
+	"maps"
+	"regexp"
+	"slices"
+	"strconv"
 	"strings"
 )
 
@@ -10,20 +14,39 @@ func countKeyTokens(key string) int {
 }
 
 type memoryState struct {
-	dict AttrValues
+	dict     AttrValues
+	texts    AttrTexts
+	policies map[AttributeID]MergePolicy
 }
 
 func (m *memoryState) GetStorage() AttrValues {
 	return m.dict
 }
 
+func (m *memoryState) GetTexts() AttrTexts {
+	return m.texts
+}
+
+func (m *memoryState) AddText(attributeId AttributeID, text string) {
+	if m.texts == nil {
+		m.texts = make(AttrTexts)
+	}
+	existing := m.texts[attributeId]
+	m.texts[attributeId] = append(existing[:len(existing):len(existing)], text)
+}
+
 type MemoryState interface {
 	GetStorage() AttrValues
+	// Add writes values to the attribute according to its merge policy.
+	Add(attributeId AttributeID, valueIds ...ValueID) error
+	// GetTexts returns free text captured for string-typed attributes.
+	GetTexts() AttrTexts
+	AddText(attributeId AttributeID, text string)
 }
 
 func NewMemoryState(memory AttrValues) MemoryState {
 	return &memoryState{
-		memory,
+		dict: memory,
 	}
 }
 
@@ -32,6 +55,11 @@ type matchState struct {
 	remainingTokens []string
 	matchedTokens   []string
 	memory          MemoryState
+	skippedTokens   []SkippedToken
+	completions     []Completion
+	// usedOnce belongs to the branch of the parse the state is on, it is copied on write
+	usedOnce map[*onceMatcher]bool
+	ctx      *parseContext
 }
 
 func (ms *matchState) HasMatch() bool {
@@ -50,11 +78,55 @@ func (ms *matchState) Memory() MemoryState {
 	return ms.memory
 }
 
+func (ms *matchState) SkippedTokens() []SkippedToken {
+	return ms.skippedTokens
+}
+
+func (ms *matchState) Completions() []Completion {
+	return ms.completions
+}
+
+func (ms *matchState) DictionaryVersions() map[string]uint64 {
+	if ms.ctx == nil || len(ms.ctx.snapshots) == 0 {
+		return nil
+	}
+	versions := make(map[string]uint64, len(ms.ctx.snapshots))
+	for dict, snapshot := range ms.ctx.snapshots {
+		versions[dict.Name()] = snapshot.version
+	}
+	return versions
+}
+
+// Diagnostics describes the furthest failure of a failed match, it is nil for successful ones.
+func (ms *matchState) Diagnostics() *Diagnostics {
+	if ms.hasMatch || ms.ctx == nil || ms.ctx.failure == nil && len(ms.ctx.conflicts) == 0 {
+		return nil
+	}
+	var diagnostics Diagnostics
+	if ms.ctx.failure != nil {
+		diagnostics = *ms.ctx.failure
+	}
+	diagnostics.Conflicts = slices.Clone(ms.ctx.conflicts)
+	return &diagnostics
+}
+
 type MatchState interface {
 	HasMatch() bool
 	RemainingTokens() []string
 	MatchedTokens() []string
 	Memory() MemoryState
+	SkippedTokens() []SkippedToken
+	Diagnostics() *Diagnostics
+	DictionaryVersions() map[string]uint64
+	// Completions lists candidates for the partially typed last token found by prefix dictionary matchers.
+	Completions() []Completion
+}
+
+type SkippedToken struct {
+	Token string
+	// Position is the number of tokens consumed before the skipped one by the full text matcher.
+	// It equals the index of the token in the input unless any-order matchers consumed tokens after it.
+	Position int
 }
 
 func Copy(state MatchState) MatchState {
@@ -63,14 +135,23 @@ func Copy(state MatchState) MatchState {
 	for k, v := range storage {
 		newMemory[k] = v
 	}
+	var policies map[AttributeID]MergePolicy
+	if memory, ok := state.Memory().(*memoryState); ok {
+		policies = memory.policies
+	}
 	return &matchState{
-		state.HasMatch(),
-		state.RemainingTokens(),
-		state.MatchedTokens(),
-		NewMemoryState(newMemory),
+		hasMatch:        state.HasMatch(),
+		remainingTokens: state.RemainingTokens(),
+		matchedTokens:   state.MatchedTokens(),
+		memory:          &memoryState{newMemory, maps.Clone(state.Memory().GetTexts()), policies},
+		skippedTokens:   state.SkippedTokens(),
+		completions:     state.Completions(),
+		usedOnce:        usedOnceOf(state),
+		ctx:             contextOf(state),
 	}
 }
 
+// NewMatchState builds a state outside of any parse, matchers should use NewMatchStateFrom instead.
 func NewMatchState(hasMatch bool, remainTokens, matchedTokens []string, memory MemoryState) MatchState {
 	return &matchState{
 		hasMatch:        hasMatch,
@@ -80,39 +161,97 @@ func NewMatchState(hasMatch bool, remainTokens, matchedTokens []string, memory M
 	}
 }
 
-func NewInitialState(tokens []string) MatchState {
+// NewMatchStateFrom builds the result of a custom matcher for the input state. Unlike NewMatchState
+// it keeps the parse context: diagnostics, tracing, dictionary snapshots, once usage and completions.
+func NewMatchStateFrom(
+	input MatchState,
+	hasMatch bool,
+	remainTokens, matchedTokens []string,
+	memory MemoryState,
+) MatchState {
+	return derive(input, hasMatch, remainTokens, matchedTokens, memory)
+}
+
+func NewInitialState(tokens []string, opts ...StateOption) MatchState {
+	ctx := &parseContext{input: tokens, inputLength: len(tokens)}
+	if len(tokens) > 0 {
+		ctx.lastToken = tokens[len(tokens)-1]
+	}
+	for _, opt := range opts {
+		opt(ctx)
+	}
 	return &matchState{
 		hasMatch:        false,
 		remainingTokens: tokens,
 		matchedTokens:   make([]string, 0),
-		memory:          NewMemoryState(make(AttrValues)),
+		memory:          &memoryState{dict: make(AttrValues), policies: ctx.mergePolicies},
+		ctx:             ctx,
 	}
 }
 
+type parseContext struct {
+	input       []string
+	inputLength int
+	lastToken   string
+	failure     *Diagnostics
+	conflicts   []MergeConflict
+	tracer      Tracer
+	depth       int
+	snapshots   map[*ReloadableDictionary]*dictionarySnapshot
+
+	mergePolicies map[AttributeID]MergePolicy
+}
+
+func contextOf(state MatchState) *parseContext {
+	if ms, ok := state.(*matchState); ok {
+		return ms.ctx
+	}
+	return nil
+}
+
+func derive(from MatchState, hasMatch bool, remainTokens, matchedTokens []string, memory MemoryState) *matchState {
+	return &matchState{
+		hasMatch:        hasMatch,
+		remainingTokens: remainTokens,
+		matchedTokens:   matchedTokens,
+		memory:          memory,
+		completions:     from.Completions(),
+		usedOnce:        usedOnceOf(from),
+		ctx:             contextOf(from),
+	}
+}
+
+func noMatch(from MatchState) *matchState {
+	return derive(from, false, from.RemainingTokens(), nil, nil)
+}
+
 type Matcher interface {
 	Match(input MatchState) MatchState
 }
 
 type allowedWordMatcher struct {
 	word string
+	o    options
 }
 
 func (w *allowedWordMatcher) Match(input MatchState) MatchState {
 	tokens := input.RemainingTokens()
 
-	if len(tokens) == 0 {
-		return NewMatchState(false, tokens, nil, nil)
+	if len(tokens) == 0 || tokens[0] != w.word {
+		reportFailure(input, w.expected())
+		return noMatch(input)
 	}
-
-	if tokens[0] == w.word {
-		return NewMatchState(true, tokens[1:], nil, input.Memory())
-	}
-	return NewMatchState(false, tokens, nil, nil)
+	return derive(input, true, tokens[1:], nil, input.Memory())
 }
 
-func NewAllowedWordMatcher(word string) Matcher {
+func NewAllowedWordMatcher(word string, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return &allowedWordMatcher{
 		word,
+		*o,
 	}
 }
 
@@ -125,7 +264,8 @@ type allowedWordsMatcher struct {
 func (w *allowedWordsMatcher) Match(input MatchState) MatchState {
 	tokens := input.RemainingTokens()
 	if len(tokens) == 0 {
-		return NewMatchState(false, tokens, nil, nil)
+		reportFailure(input, w.expected())
+		return noMatch(input)
 	}
 	needleBorder := min(w.maxKeyLength, len(tokens))
 	var matchedTokens []string
@@ -137,9 +277,10 @@ func (w *allowedWordsMatcher) Match(input MatchState) MatchState {
 		if w.o.keepMatchedTokens {
 			matchedTokens = []string{lookup}
 		}
-		return NewMatchState(true, tokens[i:], matchedTokens, input.Memory())
+		return derive(input, true, tokens[i:], matchedTokens, input.Memory())
 	}
-	return NewMatchState(false, tokens, nil, input.Memory())
+	reportFailure(input, w.expected())
+	return noMatch(input)
 }
 
 func NewAllowedWordsMatcher(words []string, opts ...Option) Matcher {
@@ -169,17 +310,18 @@ type sequenceMatcher struct {
 	o     options
 }
 
-func (s *sequenceMatcher) Match(state MatchState) MatchState {
-	tokens := state.RemainingTokens()
-	if len(tokens) == 0 {
-		return NewMatchState(false, tokens, nil, nil)
+func (s *sequenceMatcher) Match(input MatchState) MatchState {
+	if len(input.RemainingTokens()) == 0 {
+		reportExpected(input, s)
+		return noMatch(input)
 	}
 
+	state := input
 	var matchedTokens []string
 	for _, matcher := range s.words {
-		state = matcher.Match(Copy(state))
+		state = Invoke(matcher, Copy(state))
 		if !state.HasMatch() {
-			return NewMatchState(false, tokens, nil, nil)
+			return noMatch(input)
 		}
 		if !s.o.keepMatchedTokens {
 			continue
@@ -190,7 +332,8 @@ func (s *sequenceMatcher) Match(state MatchState) MatchState {
 		matchedTokens = append(matchedTokens, state.MatchedTokens()...)
 	}
 	if len(matchedTokens) > 0 {
-		return NewMatchState(
+		return derive(
+			state,
 			state.HasMatch(),
 			state.RemainingTokens(),
 			matchedTokens,
@@ -209,36 +352,48 @@ func NewSequenceMatcher(matchers []Matcher, opts ...Option) Matcher {
 }
 
 type dictMatcher struct {
-	dict         map[string][]ValueID
-	attributeId  AttributeID
-	o            options
-	maxKeyLength int
+	dict        Dictionary
+	attributeId AttributeID
+	o           options
 }
 
 func (m *dictMatcher) Match(state MatchState) MatchState {
 	tokens := state.RemainingTokens()
 	if len(tokens) == 0 {
-		return NewMatchState(false, tokens, nil, nil)
+		reportFailure(state, m.expected())
+		return noMatch(state)
 	}
-	memory := state.Memory().GetStorage()
+	memory := state.Memory()
+	dict := resolveDictionary(m.dict, state)
 
 	needleBorder := len(tokens)
 	if m.o.calculateNeedleLength {
-		needleBorder = min(m.maxKeyLength, needleBorder)
+		needleBorder = min(dict.MaxKeyLength(), needleBorder)
 	}
 	for i := needleBorder; i > 0; i-- {
 		needle := strings.Join(tokens[:i], " ")
-		if valueIds, ok := m.dict[needle]; ok {
-			memory[m.attributeId] = append(memory[m.attributeId], valueIds...)
-			var matchedTokens []string
-			if m.o.keepMatchedTokens {
-				matchedTokens = tokens[:i]
+		var matchedTokens []string
+		if m.o.keepMatchedTokens {
+			matchedTokens = tokens[:i]
+		}
+		if valueIds, ok := dict.Lookup(needle); ok {
+			if err := memory.Add(m.attributeId, valueIds...); err != nil {
+				reportConflict(state, 0, err)
+				return noMatch(state)
+			}
+			return derive(state, true, tokens[i:], matchedTokens, memory)
+		}
+		if m.o.prefixLastToken && i == len(tokens) {
+			if completions := completeLastToken(state, dict, m.attributeId, needle); completions != nil {
+				res := derive(state, true, nil, matchedTokens, memory)
+				res.completions = completions
+				return res
 			}
-			return NewMatchState(true, tokens[i:], matchedTokens, NewMemoryState(memory))
 		}
 	}
 
-	return NewMatchState(false, tokens, nil, nil)
+	reportFailure(state, m.expected())
+	return noMatch(state)
 }
 
 func (m *dictMatcher) Match_v1(state MatchState) MatchState {
@@ -247,14 +402,15 @@ func (m *dictMatcher) Match_v1(state MatchState) MatchState {
 		return NewMatchState(false, tokens, nil, nil)
 	}
 	memory := state.Memory().GetStorage()
+	dict := resolveDictionary(m.dict, state)
 
 	needleBorder := len(tokens)
 	if m.o.calculateNeedleLength {
-		needleBorder = min(m.maxKeyLength, needleBorder)
+		needleBorder = min(dict.MaxKeyLength(), needleBorder)
 	}
 	for i := needleBorder; i > 0; i-- {
 		needle := strings.Join(tokens[:i], " ")
-		if valueIds, ok := m.dict[needle]; ok {
+		if valueIds, ok := dict.Lookup(needle); ok {
 			memory[m.attributeId] = append(memory[m.attributeId], valueIds...)
 			var matchedTokens []string
 			if m.o.keepMatchedTokens {
@@ -268,110 +424,165 @@ func (m *dictMatcher) Match_v1(state MatchState) MatchState {
 }
 
 func NewDictMatcher(srcDictionary map[string][]ValueID, attributeId AttributeID, opts ...Option) Matcher {
+	return NewDictMatcherFrom(NewMapDictionary(srcDictionary), attributeId, opts...)
+}
+
+func NewDictMatcherFrom(dict Dictionary, attributeId AttributeID, opts ...Option) Matcher {
 	o := &options{}
 	for _, opt := range opts {
 		opt(o)
 	}
-	matcher := &dictMatcher{
-		srcDictionary,
+	return &dictMatcher{
+		dict,
 		attributeId,
 		*o,
-		0,
-	}
-	if !o.calculateNeedleLength {
-		return matcher
-	}
-	maxKeyLength := 0
-	for key := range srcDictionary {
-		keyLength := countKeyTokens(key)
-		if keyLength > maxKeyLength {
-			maxKeyLength = keyLength
-		}
 	}
-	matcher.maxKeyLength = maxKeyLength
-	return matcher
 }
 
 type fullTextMatcher struct {
 	nodes []Matcher
+	o     options
 }
 
-func (or *fullTextMatcher) Match(state MatchState) MatchState {
+func (or *fullTextMatcher) Match(input MatchState) MatchState {
+	state := input
+	inputLength := len(state.RemainingTokens())
+	matchedAny := false
+	var skippedTokens []SkippedToken
 	for {
 		hasMatch := false
 		for _, node := range or.nodes {
-			newState := node.Match(Copy(state))
+			newState := Invoke(node, Copy(state))
 			hasMatch = newState.HasMatch()
 			if newState.HasMatch() {
 				matchedTokens := make([]string, 0, len(state.MatchedTokens())+len(newState.MatchedTokens()))
 				matchedTokens = append(append(matchedTokens, state.MatchedTokens()...), newState.MatchedTokens()...)
-				state = NewMatchState(true, newState.RemainingTokens(), matchedTokens, newState.Memory())
+				state = derive(newState, true, newState.RemainingTokens(), matchedTokens, newState.Memory())
 				break
 			}
 		}
-		if !hasMatch {
-			break
+		if hasMatch {
+			matchedAny = true
+		} else {
+			tokens := state.RemainingTokens()
+			if !or.o.skipUnknownTokens || len(tokens) == 0 {
+				break
+			}
+			skippedTokens = append(skippedTokens, SkippedToken{
+				Token:    tokens[0],
+				Position: inputLength - len(tokens),
+			})
+			if float64(len(skippedTokens)) > or.o.maxSkippedRatio*float64(inputLength) {
+				break
+			}
+			state = derive(state, state.HasMatch(), tokens[1:], state.MatchedTokens(), state.Memory())
 		}
 		if len(state.RemainingTokens()) == 0 {
-			return state
+			if !matchedAny {
+				break
+			}
+			res := derive(state, true, state.RemainingTokens(), state.MatchedTokens(), state.Memory())
+			res.skippedTokens = skippedTokens
+			return res
 		}
 	}
-	return NewMatchState(false, state.RemainingTokens(), nil, nil)
+	return noMatch(input)
 }
 
-func NewFullTextMatcher(matchers []Matcher, _ ...Option) Matcher {
-	return &fullTextMatcher{matchers}
+func NewFullTextMatcher(matchers []Matcher, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &fullTextMatcher{matchers, *o}
 }
 
 type oneOfMatcher struct {
 	words []Matcher
+	o     options
 }
 
 func (o *oneOfMatcher) Match(state MatchState) MatchState {
 	for _, matcher := range o.words {
-		newState := matcher.Match(Copy(state))
+		newState := Invoke(matcher, Copy(state))
 		if newState.HasMatch() {
 			return newState
 		}
 	}
-	return NewMatchState(false, state.RemainingTokens(), state.MatchedTokens(), nil)
+	return noMatch(state)
 }
 
-func NewOneOfMatcher(nodes []Matcher) Matcher {
+func NewOneOfMatcher(nodes []Matcher, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return &oneOfMatcher{
 		nodes,
+		*o,
 	}
 }
 
 type onceMatcher struct {
 	matcher Matcher
-	matched bool
+	o       options
 }
 
 func (om *onceMatcher) Match(state MatchState) MatchState {
-	if om.matched {
-		return NewMatchState(false, state.RemainingTokens(), state.MatchedTokens(), nil)
+	if om.used(state) {
+		return noMatch(state)
 	}
-	res := om.matcher.Match(Copy(state))
+	res := Invoke(om.matcher, Copy(state))
 	if res.HasMatch() {
-		om.matched = true
-		return res
+		return om.markUsed(res)
+	}
+	return noMatch(state)
+}
+
+// used is tracked along the branch of the parse, so alternatives abandoned by oneOf
+// or permutation backtracking do not spend the matcher. States built by NewMatchState
+// or other MatchState implementations do not track it.
+func (om *onceMatcher) used(state MatchState) bool {
+	return usedOnceOf(state)[om]
+}
+
+// markUsed returns a copy of state with the matcher used.
+func (om *onceMatcher) markUsed(state MatchState) MatchState {
+	ms, ok := state.(*matchState)
+	if !ok {
+		return state
+	}
+	res := *ms
+	res.usedOnce = maps.Clone(ms.usedOnce)
+	if res.usedOnce == nil {
+		res.usedOnce = make(map[*onceMatcher]bool)
+	}
+	res.usedOnce[om] = true
+	return &res
+}
+
+func usedOnceOf(state MatchState) map[*onceMatcher]bool {
+	if ms, ok := state.(*matchState); ok {
+		return ms.usedOnce
 	}
-	return NewMatchState(false, state.RemainingTokens(), state.MatchedTokens(), nil)
+	return nil
 }
 
-func NewOnceMatcher(matcher Matcher) Matcher {
+func NewOnceMatcher(matcher Matcher, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
 	return &onceMatcher{
 		matcher,
-		false,
+		*o,
 	}
 }
 
 type anyOrderDictMatcher struct {
-	dict         map[string][]ValueID
-	attributeId  AttributeID
-	maxKeyLength int
-	o            options
+	dict        Dictionary
+	attributeId AttributeID
+	o           options
 }
 
 func NewAnyOrderDictMatcher(
@@ -379,46 +590,36 @@ func NewAnyOrderDictMatcher(
 	attributeId AttributeID,
 	opts ...Option,
 ) Matcher {
-	maxKeyLength := 0
-	for key := range srcDictionary {
-		keyLength := len(strings.Split(key, " "))
-		if keyLength > maxKeyLength {
-			maxKeyLength = keyLength
-		}
-	}
+	return NewAnyOrderDictMatcherFrom(NewMapDictionary(srcDictionary), attributeId, opts...)
+}
 
+func NewAnyOrderDictMatcherFrom(dict Dictionary, attributeId AttributeID, opts ...Option) Matcher {
 	o := &options{}
 	for _, opt := range opts {
 		opt(o)
 	}
 
 	return &anyOrderDictMatcher{
-		dict:         srcDictionary,
-		attributeId:  attributeId,
-		maxKeyLength: maxKeyLength,
-		o:            *o,
+		dict:        dict,
+		attributeId: attributeId,
+		o:           *o,
 	}
 }
 
 func (m *anyOrderDictMatcher) Match(state MatchState) MatchState {
 	tokens := state.RemainingTokens()
 	if len(tokens) == 0 {
-		return NewMatchState(false, tokens, nil, nil)
+		reportFailure(state, m.expected())
+		return noMatch(state)
 	}
-	memory := state.Memory().GetStorage()
+	memory := state.Memory()
+	dict := resolveDictionary(m.dict, state)
 
-	maxNeedleLen := min(len(tokens), m.maxKeyLength)
+	maxNeedleLen := min(len(tokens), dict.MaxKeyLength())
 	for length := maxNeedleLen; length >= 1; length-- {
 		for offset := 0; offset+length <= len(tokens); offset++ {
 			needleTokens := tokens[offset : offset+length]
 			needle := strings.Join(needleTokens, " ")
-
-			valueIds, dictContainsNeedle := m.dict[needle]
-			if !dictContainsNeedle {
-				continue
-			}
-
-			memory[m.attributeId] = append(memory[m.attributeId], valueIds...)
 			remainingTokens := calculateRemainingTokens(tokens, offset, length)
 
 			var matchedTokens []string
@@ -426,11 +627,30 @@ func (m *anyOrderDictMatcher) Match(state MatchState) MatchState {
 				matchedTokens = needleTokens
 			}
 
-			return NewMatchState(true, remainingTokens, matchedTokens, NewMemoryState(memory))
+			valueIds, dictContainsNeedle := dict.Lookup(needle)
+			if !dictContainsNeedle {
+				if !m.o.prefixLastToken || offset+length < len(tokens) {
+					continue
+				}
+				completions := completeLastToken(state, dict, m.attributeId, needle)
+				if completions == nil {
+					continue
+				}
+				res := derive(state, true, remainingTokens, matchedTokens, memory)
+				res.completions = completions
+				return res
+			}
+
+			if err := memory.Add(m.attributeId, valueIds...); err != nil {
+				reportConflict(state, offset, err)
+				return noMatch(state)
+			}
+			return derive(state, true, remainingTokens, matchedTokens, memory)
 		}
 	}
 
-	return NewMatchState(false, tokens, nil, nil)
+	reportFailure(state, m.expected())
+	return noMatch(state)
 }
 
 func calculateRemainingTokens(tokens []string, matchedOffset, matchedLen int) []string {
@@ -454,16 +674,22 @@ func calculateRemainingTokens(tokens []string, matchedOffset, matchedLen int) []
 
 type tryAllMatcher struct {
 	nodes []Matcher
+	o     options
 }
 
-func NewTryAllMatcher(nodes []Matcher) Matcher {
-	return &tryAllMatcher{nodes: nodes}
+func NewTryAllMatcher(nodes []Matcher, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &tryAllMatcher{nodes: nodes, o: *o}
 }
 
-func (rr *tryAllMatcher) Match(state MatchState) MatchState {
+func (rr *tryAllMatcher) Match(input MatchState) MatchState {
+	state := input
 	hasAnyMatch := false
 	for _, node := range rr.nodes {
-		newState := node.Match(Copy(state))
+		newState := Invoke(node, Copy(state))
 		if newState.HasMatch() {
 			state = newState
 			hasAnyMatch = true
@@ -474,5 +700,360 @@ func (rr *tryAllMatcher) Match(state MatchState) MatchState {
 		return state
 	}
 
-	return NewMatchState(false, state.RemainingTokens(), nil, state.Memory())
+	return noMatch(input)
+}
+
+type permutationMatcher struct {
+	required []Matcher
+	optional []Matcher
+	o        options
+}
+
+func NewPermutationMatcher(required, optional []Matcher, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &permutationMatcher{
+		required: required,
+		optional: optional,
+		o:        *o,
+	}
+}
+
+func (p *permutationMatcher) Match(state MatchState) MatchState {
+	if len(state.RemainingTokens()) == 0 {
+		reportExpected(state, p)
+		return noMatch(state)
+	}
+
+	nodes := make([]Matcher, 0, len(p.required)+len(p.optional))
+	nodes = append(append(nodes, p.required...), p.optional...)
+	used := make([]bool, len(nodes))
+
+	res, ok := p.permute(nodes, used, state, nil)
+	if !ok {
+		return noMatch(state)
+	}
+	return res
+}
+
+func (p *permutationMatcher) permute(nodes []Matcher, used []bool, state MatchState, matchedTokens []string) (MatchState, bool) {
+	if len(state.RemainingTokens()) > 0 {
+		for i, node := range nodes {
+			if used[i] {
+				continue
+			}
+			newState := Invoke(node, Copy(state))
+			if !newState.HasMatch() {
+				continue
+			}
+			used[i] = true
+			var nextMatched []string
+			if p.o.keepMatchedTokens {
+				nextMatched = make([]string, 0, len(matchedTokens)+len(newState.MatchedTokens()))
+				nextMatched = append(append(nextMatched, matchedTokens...), newState.MatchedTokens()...)
+			}
+			if res, ok := p.permute(nodes, used, newState, nextMatched); ok {
+				return res, true
+			}
+			used[i] = false
+		}
+	}
+
+	matchedAny := false
+	for i := range nodes {
+		if !used[i] {
+			if i < len(p.required) {
+				return nil, false
+			}
+			continue
+		}
+		matchedAny = true
+	}
+	if !matchedAny {
+		return nil, false
+	}
+	return derive(state, true, state.RemainingTokens(), matchedTokens, state.Memory()), true
+}
+
+type ActionContext struct {
+	// Tokens are the tokens consumed by the child, in input order.
+	Tokens []string
+	// Start and End delimit the consumed tokens as token indexes of the parsed input,
+	// tokens the child skipped over in between are included in the span.
+	Start int
+	End   int
+	// Memory is a snapshot, values are written through the returned AttrValues only.
+	Memory AttrValues
+}
+
+// Action returns values to write to memory after the child matched, or an error to reject the match.
+type Action func(ctx ActionContext) (AttrValues, error)
+
+type actionMatcher struct {
+	matcher Matcher
+	action  Action
+	o       options
+}
+
+func NewActionMatcher(matcher Matcher, action Action, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &actionMatcher{matcher, action, *o}
+}
+
+func (a *actionMatcher) Match(state MatchState) MatchState {
+	res := Invoke(a.matcher, Copy(state))
+	if !res.HasMatch() {
+		return noMatch(state)
+	}
+	return a.apply(state, res)
+}
+
+// apply runs the action over the child result res obtained from state.
+func (a *actionMatcher) apply(state, res MatchState) MatchState {
+	actionCtx := ActionContext{Memory: maps.Clone(res.Memory().GetStorage())}
+	actionCtx.Tokens, actionCtx.Start, actionCtx.End = consumedSpan(state, res.RemainingTokens())
+
+	values, err := a.action(actionCtx)
+	if err != nil {
+		return noMatch(state)
+	}
+	memory := res.Memory()
+	for _, attributeId := range sortedKeys(values) {
+		if err := memory.Add(attributeId, values[attributeId]...); err != nil {
+			reportConflict(state, 0, err)
+			return noMatch(state)
+		}
+	}
+	return res
+}
+
+// consumedIndexes returns indexes of tokens missing from remaining, which is a subsequence of tokens.
+// Any-order matchers remove tokens from the middle, so the alignment goes from the end:
+// prefix matchers leave a suffix of tokens and are aligned exactly.
+func consumedIndexes(tokens, remaining []string) []int {
+	consumed := make([]int, 0, len(tokens)-len(remaining))
+	j := len(remaining) - 1
+	for i := len(tokens) - 1; i >= 0; i-- {
+		if j >= 0 && tokens[i] == remaining[j] {
+			j--
+			continue
+		}
+		consumed = append(consumed, i)
+	}
+	slices.Reverse(consumed)
+	return consumed
+}
+
+// consumedSpan returns the tokens of state missing from remaining together with the span
+// they occupy in the parsed input.
+func consumedSpan(state MatchState, remaining []string) ([]string, int, int) {
+	tokens := state.RemainingTokens()
+	positions := inputPositions(contextOf(state), tokens)
+	consumed := consumedIndexes(tokens, remaining)
+	res := make([]string, 0, len(consumed))
+	for _, i := range consumed {
+		res = append(res, tokens[i])
+	}
+	if len(consumed) == 0 {
+		start := inputPosition(state)
+		return res, start, start
+	}
+	return res, positions[consumed[0]], positions[consumed[len(consumed)-1]] + 1
+}
+
+// inputPosition returns the index of the first remaining token in the parsed input.
+func inputPosition(state MatchState) int {
+	ctx := contextOf(state)
+	if ctx == nil {
+		return 0
+	}
+	if tokens := state.RemainingTokens(); len(tokens) > 0 {
+		return inputPositions(ctx, tokens)[0]
+	}
+	return ctx.inputLength
+}
+
+// inputPositions maps the remaining tokens to their indexes in the parsed input, aligning them
+// from the end as any-order matchers remove tokens from the middle. Tokens which do not come
+// from the input are counted from the end of it.
+func inputPositions(ctx *parseContext, tokens []string) []int {
+	positions := make([]int, len(tokens))
+	offset := 0
+	if ctx != nil {
+		offset = ctx.inputLength - len(tokens)
+	}
+	for i := range positions {
+		positions[i] = offset + i
+	}
+	if ctx == nil {
+		return positions
+	}
+
+	aligned := make([]int, len(tokens))
+	j := len(ctx.input) - 1
+	for i := len(tokens) - 1; i >= 0; i-- {
+		for j >= 0 && ctx.input[j] != tokens[i] {
+			j--
+		}
+		if j < 0 {
+			return positions
+		}
+		aligned[i] = j
+		j--
+	}
+	return aligned
+}
+
+type guardMatcher struct {
+	condition Condition
+	matcher   Matcher
+	o         options
+}
+
+// NewGuardMatcher lets the matcher run only when the condition holds for the memory extracted so far.
+func NewGuardMatcher(condition Condition, matcher Matcher, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &guardMatcher{condition, matcher, *o}
+}
+
+func (g *guardMatcher) Match(state MatchState) MatchState {
+	if !g.condition(state.Memory().GetStorage()) {
+		reportFailure(state, g.expected())
+		return noMatch(state)
+	}
+	res := Invoke(g.matcher, Copy(state))
+	if !res.HasMatch() {
+		return noMatch(state)
+	}
+	return res
+}
+
+type captureMatcher struct {
+	attributeId AttributeID
+	o           options
+}
+
+// NewCaptureMatcher consumes arbitrary tokens and stores them joined by spaces as text of the attribute.
+// It takes tokens until MaxTokens is reached, a StopWords token or a position where StopAt matches.
+func NewCaptureMatcher(attributeId AttributeID, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &captureMatcher{attributeId, *o}
+}
+
+func (c *captureMatcher) Match(state MatchState) MatchState {
+	tokens := state.RemainingTokens()
+	count := 0
+	for count < len(tokens) && (c.o.maxTokens == 0 || count < c.o.maxTokens) && !c.stopsAt(state, tokens[count:]) {
+		count++
+	}
+	if count == 0 {
+		reportFailure(state, c.expected())
+		return noMatch(state)
+	}
+
+	memory := state.Memory()
+	memory.AddText(c.attributeId, strings.Join(tokens[:count], " "))
+	var matchedTokens []string
+	if c.o.keepMatchedTokens {
+		matchedTokens = tokens[:count]
+	}
+	return derive(state, true, tokens[count:], matchedTokens, memory)
+}
+
+func (c *captureMatcher) stopsAt(state MatchState, tokens []string) bool {
+	if _, ok := c.o.stopWords[tokens[0]]; ok {
+		return true
+	}
+	if c.o.stopAt == nil {
+		return false
+	}
+	probe := derive(state, false, tokens, nil, Copy(state).Memory())
+	if probe.ctx != nil {
+		// the probe sees the pinned snapshots, but its failures are discarded
+		ctx := *probe.ctx
+		ctx.failure, ctx.conflicts, ctx.tracer = nil, nil, nil
+		probe.ctx = &ctx
+	}
+	return c.o.stopAt.Match(probe).HasMatch()
+}
+
+// RegexpGroup maps a named group to an attribute. Text groups are stored as text as is,
+// others are parsed as integer values, so "012345" becomes 12345.
+type RegexpGroup struct {
+	Attribute AttributeID
+	Type      ValueType
+}
+
+type regexpMatcher struct {
+	expr     *regexp.Regexp
+	anchored *regexp.Regexp
+	groups   map[string]RegexpGroup
+	o        options
+}
+
+// NewRegexpMatcher matches the expression against the whole token or, with MaxTokens, against
+// the longest window of space-joined tokens. Named groups are written to the mapped attributes,
+// a window whose value group is not an integer does not match.
+func NewRegexpMatcher(expr *regexp.Regexp, groups map[string]RegexpGroup, opts ...Option) Matcher {
+	o := &options{}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return &regexpMatcher{
+		expr:     expr,
+		anchored: regexp.MustCompile(`^(?:` + expr.String() + `)$`),
+		groups:   groups,
+		o:        *o,
+	}
+}
+
+func (r *regexpMatcher) Match(state MatchState) MatchState {
+	tokens := state.RemainingTokens()
+	window := min(max(r.o.maxTokens, 1), len(tokens))
+windows:
+	for i := window; i > 0; i-- {
+		text := strings.Join(tokens[:i], " ")
+		submatches := r.anchored.FindStringSubmatch(text)
+		if submatches == nil {
+			continue
+		}
+
+		memory := Copy(state).Memory()
+		for j, name := range r.anchored.SubexpNames() {
+			group, ok := r.groups[name]
+			if !ok || name == "" || submatches[j] == "" {
+				continue
+			}
+			if group.Type == ValueText {
+				memory.AddText(group.Attribute, submatches[j])
+				continue
+			}
+			value, err := strconv.ParseInt(submatches[j], 10, 64)
+			if err != nil {
+				continue windows
+			}
+			if err := memory.Add(group.Attribute, value); err != nil {
+				reportConflict(state, 0, err)
+				return noMatch(state)
+			}
+		}
+		var matchedTokens []string
+		if r.o.keepMatchedTokens {
+			matchedTokens = tokens[:i]
+		}
+		return derive(state, true, tokens[i:], matchedTokens, memory)
+	}
+	reportFailure(state, r.expected())
+	return noMatch(state)
 }
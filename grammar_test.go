@@ -3,6 +3,7 @@ package context_free_grammar
 import (
 	"reflect"
 	"strings"
+	"sync"
 	"testing"
 
 	"github.com/stretchr/testify/require"
@@ -170,7 +171,7 @@ func TestOnceMatcher_Match(t *testing.T) {
 	testNegativeParse(t, res)
 }
 
-func Test_OnceMatcher_Match_Fail(t *testing.T) {
+func Test_OnceMatcher_Match_AbandonedBranch(t *testing.T) {
 	allowedWordA := NewAllowedWordMatcher("A")
 	allowedWordB := NewAllowedWordMatcher("B")
 	allowedWordC := NewAllowedWordMatcher("C")
@@ -187,8 +188,30 @@ func Test_OnceMatcher_Match_Fail(t *testing.T) {
 	tokens := getTokens(query)
 	state := NewInitialState(tokens)
 
+	// "A" matched by the abandoned ftAB branch stays available to ftAC
 	state = root.Match(state)
-	require.False(t, state.HasMatch())
+	testPositiveParse(t, state)
+}
+
+func TestOnceMatcher_PerParse(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{NewOnceMatcher(NewAllowedWordMatcher("lorem")), NewAllowedWordMatcher("ipsum")})
+	testPositiveParse(t, root.Match(NewInitialState(getTokens("lorem ipsum"))))
+	testPositiveParse(t, root.Match(NewInitialState(getTokens("ipsum lorem"))))
+	testNegativeParse(t, root.Match(NewInitialState(getTokens("lorem lorem"))))
+
+	results := make([]MatchState, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = root.Match(NewInitialState(getTokens("lorem ipsum")))
+		}(i)
+	}
+	wg.Wait()
+	for _, res := range results {
+		testPositiveParse(t, res)
+	}
 }
 
 func TestAllowedWordsMatcher_Match(t *testing.T) {
@@ -580,3 +603,159 @@ func Test_tryAllMatcher_Match(t *testing.T) {
 	}
 }
 
+func TestPermutationMatcher_Match(t *testing.T) {
+	newMatcher := func() Matcher {
+		return NewPermutationMatcher(
+			[]Matcher{
+				NewDictMatcher(map[string][]ValueID{"2к": {2}, "3к": {3}}, 1, KeepMatchedTokens()),
+				NewDictMatcher(map[string][]ValueID{"москва": {10}}, 2, KeepMatchedTokens()),
+			},
+			[]Matcher{
+				NewDictMatcher(map[string][]ValueID{"новостройка": {20}}, 3, KeepMatchedTokens()),
+			},
+			KeepMatchedTokens(),
+		)
+	}
+
+	tests := []struct {
+		name                  string
+		query                 string
+		hasMatch              bool
+		expectedParams        AttrValues
+		expectedMatchedTokens []string
+	}{
+		{
+			name:     "Should match all nodes in direct order",
+			query:    "новостройка 2к москва",
+			hasMatch: true,
+			expectedParams: AttrValues{
+				1: {2},
+				2: {10},
+				3: {20},
+			},
+			expectedMatchedTokens: []string{"новостройка", "2к", "москва"},
+		},
+		{
+			name:     "Should match all nodes in reverse order",
+			query:    "москва 2к новостройка",
+			hasMatch: true,
+			expectedParams: AttrValues{
+				1: {2},
+				2: {10},
+				3: {20},
+			},
+			expectedMatchedTokens: []string{"москва", "2к", "новостройка"},
+		},
+		{
+			name:     "Should match without optional node",
+			query:    "3к москва",
+			hasMatch: true,
+			expectedParams: AttrValues{
+				1: {3},
+				2: {10},
+			},
+			expectedMatchedTokens: []string{"3к", "москва"},
+		},
+		{
+			name:     "Should NOT match without required node",
+			query:    "новостройка москва",
+			hasMatch: false,
+		},
+		{
+			name:     "Should NOT match the same node twice",
+			query:    "2к 3к москва",
+			hasMatch: false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			root := NewFullTextMatcher([]Matcher{newMatcher()})
+			res := root.Match(NewInitialState(getTokens(tt.query)))
+			require.Equal(t, tt.hasMatch, res.HasMatch())
+			if !tt.hasMatch {
+				return
+			}
+			testDictParserResult(t, res, tt.expectedParams)
+			require.Equal(t, tt.expectedMatchedTokens, res.MatchedTokens())
+		})
+	}
+
+	t.Run("Should NOT spend once nodes of abandoned orders", func(t *testing.T) {
+		root := NewPermutationMatcher([]Matcher{
+			NewOnceMatcher(NewAllowedWordMatcher("a")),
+			NewSequenceMatcher([]Matcher{NewAllowedWordMatcher("a"), NewAllowedWordMatcher("b")}),
+		}, nil)
+		testPositiveParse(t, root.Match(NewInitialState(getTokens("a b a"))))
+	})
+}
+
+func TestFullTextMatcher_SkipUnknownTokens(t *testing.T) {
+	newMatcher := func(maxSkippedRatio float64) Matcher {
+		return NewFullTextMatcher([]Matcher{
+			NewDictMatcher(map[string][]ValueID{"2к": {2}}, 1),
+			NewDictMatcher(map[string][]ValueID{"москва": {10}}, 2),
+			NewAllowedWordMatcher("квартира"),
+		}, SkipUnknownTokens(maxSkippedRatio))
+	}
+
+	tests := []struct {
+		name                  string
+		query                 string
+		maxSkippedRatio       float64
+		hasMatch              bool
+		expectedParams        AttrValues
+		expectedSkippedTokens []SkippedToken
+	}{
+		{
+			name:            "Should keep memory and report skipped tokens",
+			query:           "2к уютная квартира москва недорого",
+			maxSkippedRatio: 0.5,
+			hasMatch:        true,
+			expectedParams: AttrValues{
+				1: {2},
+				2: {10},
+			},
+			expectedSkippedTokens: []SkippedToken{
+				{Token: "уютная", Position: 1},
+				{Token: "недорого", Position: 4},
+			},
+		},
+		{
+			name:            "Should match without skipped tokens",
+			query:           "2к квартира москва",
+			maxSkippedRatio: 0.5,
+			hasMatch:        true,
+			expectedParams: AttrValues{
+				1: {2},
+				2: {10},
+			},
+		},
+		{
+			name:            "Should NOT match when skipped ratio exceeds limit",
+			query:           "2к уютная квартира москва недорого",
+			maxSkippedRatio: 0.3,
+			hasMatch:        false,
+		},
+		{
+			name:            "Should NOT match when every token is skipped",
+			query:           "уютная недорого",
+			maxSkippedRatio: 1,
+			hasMatch:        false,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			res := newMatcher(tt.maxSkippedRatio).Match(NewInitialState(getTokens(tt.query)))
+			require.Equal(t, tt.hasMatch, res.HasMatch())
+			if !tt.hasMatch {
+				require.Nil(t, res.Memory())
+				return
+			}
+			testPositiveParse(t, res)
+			testDictParserResult(t, res, tt.expectedParams)
+			require.Equal(t, tt.expectedSkippedTokens, res.SkippedTokens())
+		})
+	}
+}
@@ -0,0 +1,243 @@
+// Package grammartest runs grammars against golden files of queries and their expected parse results.
+package grammartest
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"slices"
+	"sort"
+	"strings"
+	"testing"
+
+	"gopkg.in/yaml.v3"
+
+	cfg "go.avito.ru/gl/context-free-grammar"
+)
+
+var update = flag.Bool("grammartest.update", false, "rewrite golden files with the actual parse results")
+
+type Case struct {
+	Query      string                            `json:"query" yaml:"query"`
+	Attributes map[cfg.AttributeID][]cfg.ValueID `json:"attributes,omitempty" yaml:"attributes,omitempty"`
+	Texts      map[cfg.AttributeID][]string      `json:"texts,omitempty" yaml:"texts,omitempty"`
+	// MatchedTokens are compared only when listed in the golden file.
+	MatchedTokens []string `json:"matchedTokens,omitempty" yaml:"matchedTokens,omitempty"`
+	// Fail expects the grammar to reject the query.
+	Fail bool `json:"fail,omitempty" yaml:"fail,omitempty"`
+}
+
+type options struct {
+	tokenize     func(query string) []string
+	stateOptions []cfg.StateOption
+	update       bool
+}
+
+type Option func(opt *options)
+
+// Tokenizer replaces splitting queries by whitespace.
+func Tokenizer(tokenize func(query string) []string) Option {
+	return func(opt *options) {
+		opt.tokenize = tokenize
+	}
+}
+
+func StateOptions(stateOptions ...cfg.StateOption) Option {
+	return func(opt *options) {
+		opt.stateOptions = append(opt.stateOptions, stateOptions...)
+	}
+}
+
+// Update rewrites the golden file instead of checking it, as does the -grammartest.update flag.
+func Update() Option {
+	return func(opt *options) {
+		opt.update = true
+	}
+}
+
+func newOptions(opts []Option) *options {
+	o := &options{tokenize: strings.Fields, update: *update}
+	for _, opt := range opts {
+		opt(o)
+	}
+	return o
+}
+
+// Run parses every query of the golden file with root and reports mismatches as failed subtests.
+func Run(t *testing.T, path string, root cfg.Matcher, opts ...Option) {
+	t.Helper()
+	o := newOptions(opts)
+	cases, err := Load(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if o.update {
+		for i := range cases {
+			cases[i], _ = evaluate(root, cases[i], o)
+		}
+		if err := Save(path, cases); err != nil {
+			t.Fatal(err)
+		}
+		return
+	}
+	for _, expected := range cases {
+		expected := expected
+		t.Run(expected.Query, func(t *testing.T) {
+			actual, state := evaluate(root, expected, o)
+			diff := Diff(expected, actual)
+			if diff == "" {
+				return
+			}
+			if diagnostics := state.Diagnostics(); actual.Fail && diagnostics != nil {
+				diff += "\n" + diagnostics.String()
+			}
+			t.Errorf("%s: query %q:\n%s", path, expected.Query, diff)
+		})
+	}
+}
+
+// Evaluate parses the query of the case and returns the actual result in the same form.
+func Evaluate(root cfg.Matcher, expected Case, opts ...Option) Case {
+	actual, _ := evaluate(root, expected, newOptions(opts))
+	return actual
+}
+
+func evaluate(root cfg.Matcher, expected Case, o *options) (Case, cfg.MatchState) {
+	state := root.Match(cfg.NewInitialState(o.tokenize(expected.Query), o.stateOptions...))
+	actual := Case{Query: expected.Query}
+	if !state.HasMatch() || len(state.RemainingTokens()) > 0 {
+		actual.Fail = true
+		return actual, state
+	}
+	if storage := state.Memory().GetStorage(); len(storage) > 0 {
+		actual.Attributes = storage
+	}
+	if texts := state.Memory().GetTexts(); len(texts) > 0 {
+		actual.Texts = texts
+	}
+	if expected.MatchedTokens != nil {
+		actual.MatchedTokens = state.MatchedTokens()
+	}
+	return actual, state
+}
+
+// Diff describes the differences between the cases line by line, it is empty when they match.
+func Diff(expected, actual Case) string {
+	var lines []string
+	if expected.Fail != actual.Fail {
+		lines = append(lines, fmt.Sprintf("fail: want %t, got %t", expected.Fail, actual.Fail))
+	}
+	for _, attributeId := range unionKeys(expected.Attributes, actual.Attributes) {
+		want, got := expected.Attributes[attributeId], actual.Attributes[attributeId]
+		if !slices.Equal(want, got) {
+			lines = append(lines, fmt.Sprintf("attribute %d: want %v, got %v", attributeId, want, got))
+		}
+	}
+	for _, attributeId := range unionKeys(expected.Texts, actual.Texts) {
+		want, got := expected.Texts[attributeId], actual.Texts[attributeId]
+		if !slices.Equal(want, got) {
+			lines = append(lines, fmt.Sprintf("text %d: want %q, got %q", attributeId, want, got))
+		}
+	}
+	if expected.MatchedTokens != nil && !slices.Equal(expected.MatchedTokens, actual.MatchedTokens) {
+		lines = append(lines, fmt.Sprintf("matched tokens: want %q, got %q", expected.MatchedTokens, actual.MatchedTokens))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func unionKeys[V any](a, b map[cfg.AttributeID]V) []cfg.AttributeID {
+	keys := make([]cfg.AttributeID, 0, len(a)+len(b))
+	for key := range a {
+		keys = append(keys, key)
+	}
+	for key := range b {
+		if _, ok := a[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Slice(keys, func(i, j int) bool { return keys[i] < keys[j] })
+	return keys
+}
+
+// Load reads a golden file, a YAML list of cases or JSON lines with a case per line.
+func Load(path string) ([]Case, error) {
+	jsonLines, err := isJSONLines(path)
+	if err != nil {
+		return nil, err
+	}
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var cases []Case
+	if !jsonLines {
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err := decoder.Decode(&cases); err != nil && !errors.Is(err, io.EOF) {
+			return nil, fmt.Errorf("%s: %w", path, err)
+		}
+		return cases, nil
+	}
+
+	scanner := bufio.NewScanner(bytes.NewReader(data))
+	for line := 1; scanner.Scan(); line++ {
+		if len(bytes.TrimSpace(scanner.Bytes())) == 0 {
+			continue
+		}
+		var c Case
+		decoder := json.NewDecoder(bytes.NewReader(scanner.Bytes()))
+		decoder.DisallowUnknownFields()
+		if err := decoder.Decode(&c); err != nil {
+			return nil, fmt.Errorf("%s:%d: %w", path, line, err)
+		}
+		cases = append(cases, c)
+	}
+	return cases, scanner.Err()
+}
+
+// Save writes the cases in the format given by the file extension.
+func Save(path string, cases []Case) error {
+	jsonLines, err := isJSONLines(path)
+	if err != nil {
+		return err
+	}
+
+	var b bytes.Buffer
+	if jsonLines {
+		encoder := json.NewEncoder(&b)
+		encoder.SetEscapeHTML(false)
+		for _, c := range cases {
+			if err := encoder.Encode(c); err != nil {
+				return err
+			}
+		}
+	} else {
+		encoder := yaml.NewEncoder(&b)
+		encoder.SetIndent(2)
+		if err := encoder.Encode(cases); err != nil {
+			return err
+		}
+		if err := encoder.Close(); err != nil {
+			return err
+		}
+	}
+	return os.WriteFile(path, b.Bytes(), 0o644)
+}
+
+func isJSONLines(path string) (bool, error) {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".jsonl":
+		return true, nil
+	case ".yaml", ".yml":
+		return false, nil
+	}
+	return false, fmt.Errorf("unsupported golden file extension: %q", path)
+}
@@ -0,0 +1,71 @@
+package grammartest
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+
+	cfg "go.avito.ru/gl/context-free-grammar"
+)
+
+func testGrammar() cfg.Matcher {
+	return cfg.NewFullTextMatcher([]cfg.Matcher{
+		cfg.NewAllowedWordsMatcher([]string{"сниму", "куплю", "квартира", "в"}, cfg.KeepMatchedTokens()),
+		cfg.NewDictMatcher(map[string][]cfg.ValueID{"двухкомнатная": {2}}, 1, cfg.KeepMatchedTokens()),
+		cfg.NewDictMatcher(map[string][]cfg.ValueID{"москва": {77}, "санкт петербург": {78}}, 2, cfg.KeepMatchedTokens()),
+	})
+}
+
+func TestRun(t *testing.T) {
+	Run(t, "testdata/flats.yaml", testGrammar())
+	Run(t, "testdata/flats.jsonl", testGrammar())
+}
+
+func TestDiff(t *testing.T) {
+	expected := Case{
+		Query:         "квартира в москве",
+		Attributes:    map[cfg.AttributeID][]cfg.ValueID{1: {2}, 2: {77}},
+		MatchedTokens: []string{"квартира"},
+	}
+	require.Empty(t, Diff(expected, expected))
+
+	actual := Evaluate(testGrammar(), expected)
+	require.Equal(t, Case{Query: "квартира в москве", Fail: true}, actual)
+	require.Equal(t, `fail: want false, got true
+attribute 1: want [2], got []
+attribute 2: want [77], got []
+matched tokens: want ["квартира"], got []`, Diff(expected, actual))
+
+	actual = Evaluate(testGrammar(), Case{Query: "квартира в санкт петербург", MatchedTokens: []string{}})
+	require.Equal(t, `attribute 2: want [], got [78]
+matched tokens: want [], got ["квартира" "в" "санкт" "петербург"]`, Diff(Case{MatchedTokens: []string{}}, actual))
+}
+
+func TestRun_Update(t *testing.T) {
+	for _, name := range []string{"flats.yaml", "flats.jsonl"} {
+		path := filepath.Join(t.TempDir(), name)
+		require.NoError(t, Save(path, []Case{
+			{Query: "сниму москва", Fail: true},
+			{Query: "квартира в санкт петербург", MatchedTokens: []string{"квартира"}},
+			{Query: "сдам"},
+		}))
+
+		Run(t, path, testGrammar(), Update())
+		cases, err := Load(path)
+		require.NoError(t, err)
+		require.Equal(t, []Case{
+			{Query: "сниму москва", Attributes: map[cfg.AttributeID][]cfg.ValueID{2: {77}}},
+			{
+				Query:         "квартира в санкт петербург",
+				Attributes:    map[cfg.AttributeID][]cfg.ValueID{2: {78}},
+				MatchedTokens: []string{"квартира", "в", "санкт", "петербург"},
+			},
+			{Query: "сдам", Fail: true},
+		}, cases)
+		Run(t, path, testGrammar())
+	}
+
+	_, err := Load("testdata/flats.txt")
+	require.ErrorContains(t, err, "unsupported golden file extension")
+}
@@ -0,0 +1,50 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestGuardMatcher(t *testing.T) {
+	const attrArea, attrUnit AttributeID = 30, 31
+	numbers := NewDictMatcher(map[string][]ValueID{"50": {50}, "5": {5}}, attrArea)
+	meters := NewGuardMatcher(Has(attrArea), NewDictMatcher(map[string][]ValueID{"м": {1}}, attrUnit))
+	metro := NewGuardMatcher(
+		Not(Has(attrArea)),
+		NewSequenceMatcher([]Matcher{
+			NewAllowedWordMatcher("м"),
+			NewDictMatcher(map[string][]ValueID{"арбатская": {12}}, attrMetro),
+		}),
+		WithName("metro"),
+	)
+	root := NewFullTextMatcher([]Matcher{numbers, meters, metro})
+
+	res := root.Match(NewInitialState(getTokens("50 м")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrArea: {50}, attrUnit: {1}})
+
+	res = root.Match(NewInitialState(getTokens("м арбатская")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrMetro: {12}})
+
+	res = root.Match(NewInitialState(getTokens("5 м арбатская")))
+	testNegativeParse(t, res)
+	require.Equal(
+		t,
+		`unexpected "арбатская" at position 2, expected dictionary(attribute=30), dictionary(attribute=31), guard(metro)`,
+		res.Diagnostics().String(),
+	)
+
+	res = NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("x"),
+		NewGuardMatcher(Has(5), NewAllowedWordMatcher("y")),
+	}).Match(NewInitialState(getTokens("x y")))
+	testNegativeParse(t, res)
+	require.Equal(t, `unexpected "y" at position 1, expected guard("y")`, res.Diagnostics().String())
+
+	res = NewSequenceMatcher([]Matcher{metro, numbers, meters}).Match(NewInitialState(getTokens("м арбатская 5 м")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrMetro: {12}, attrArea: {5}, attrUnit: {1}})
+	require.Equal(t, "metro: guard", Label(metro))
+}
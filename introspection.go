@@ -0,0 +1,272 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"reflect"
+)
+
+type Named interface {
+	Name() string
+}
+
+type Composite interface {
+	Children() []Matcher
+}
+
+type Describer interface {
+	Describe() string
+}
+
+type Visitor interface {
+	Visit(m Matcher) (w Visitor)
+}
+
+// Walk traverses the matcher tree in depth-first order like ast.Walk: it calls
+// v.Visit(m), walks the children of m with the returned visitor and finishes
+// with a Visit(nil) call when that visitor is not nil.
+func Walk(v Visitor, m Matcher) {
+	if v = v.Visit(m); v == nil {
+		return
+	}
+	if c, ok := m.(Composite); ok {
+		for _, child := range c.Children() {
+			Walk(v, child)
+		}
+	}
+	v.Visit(nil)
+}
+
+type inspector func(Matcher) bool
+
+func (f inspector) Visit(m Matcher) Visitor {
+	if f(m) {
+		return f
+	}
+	return nil
+}
+
+func Inspect(m Matcher, f func(Matcher) bool) {
+	Walk(inspector(f), m)
+}
+
+type pathVisitor struct {
+	parent  Matcher
+	path    string
+	next    int
+	visited map[Matcher]bool
+	fn      func(m, parent Matcher, path string)
+}
+
+func (v *pathVisitor) Visit(m Matcher) Visitor {
+	if m == nil {
+		return nil
+	}
+	path := pathSegment(m, -1)
+	if v.parent != nil {
+		path = v.path + "/" + pathSegment(m, v.next)
+		v.next++
+	}
+	if reflect.TypeOf(m).Comparable() {
+		if v.visited[m] {
+			return nil
+		}
+		v.visited[m] = true
+	}
+	v.fn(m, v.parent, path)
+	return &pathVisitor{parent: m, path: path, visited: v.visited, fn: v.fn}
+}
+
+// walkPaths visits every distinct matcher once together with its parent and path from the root.
+func walkPaths(root Matcher, fn func(m, parent Matcher, path string)) {
+	Walk(&pathVisitor{visited: make(map[Matcher]bool), fn: fn}, root)
+}
+
+func Label(m Matcher) string {
+	description := fmt.Sprintf("%T", m)
+	if d, ok := m.(Describer); ok {
+		description = d.Describe()
+	}
+	if n, ok := m.(Named); ok && n.Name() != "" {
+		return n.Name() + ": " + description
+	}
+	return description
+}
+
+func matcherKind(m Matcher) string {
+	switch m.(type) {
+	case *allowedWordMatcher:
+		return "word"
+	case *allowedWordsMatcher:
+		return "words"
+	case *dictMatcher:
+		return "dictionary"
+	case *anyOrderDictMatcher:
+		return "anyOrderDictionary"
+	case *sequenceMatcher:
+		return "sequence"
+	case *fullTextMatcher:
+		return "fullText"
+	case *oneOfMatcher:
+		return "oneOf"
+	case *onceMatcher:
+		return "once"
+	case *tryAllMatcher:
+		return "tryAll"
+	case *permutationMatcher:
+		return "permutation"
+	case *actionMatcher:
+		return "action"
+	case *guardMatcher:
+		return "guard"
+	case *captureMatcher:
+		return "capture"
+	case *regexpMatcher:
+		return "regexp"
+	}
+	return fmt.Sprintf("%T", m)
+}
+
+func (w *allowedWordMatcher) Name() string {
+	return w.o.name
+}
+
+func (w *allowedWordMatcher) Describe() string {
+	return w.expected()
+}
+
+func (w *allowedWordsMatcher) Name() string {
+	return w.o.name
+}
+
+func (w *allowedWordsMatcher) Describe() string {
+	return "words(" + w.expected() + ")"
+}
+
+func (s *sequenceMatcher) Name() string {
+	return s.o.name
+}
+
+func (s *sequenceMatcher) Describe() string {
+	return "sequence"
+}
+
+func (s *sequenceMatcher) Children() []Matcher {
+	return s.words
+}
+
+func (m *dictMatcher) Name() string {
+	return m.o.name
+}
+
+func (m *dictMatcher) Describe() string {
+	return m.expected()
+}
+
+func (or *fullTextMatcher) Name() string {
+	return or.o.name
+}
+
+func (or *fullTextMatcher) Describe() string {
+	return "fullText"
+}
+
+func (or *fullTextMatcher) Children() []Matcher {
+	return or.nodes
+}
+
+func (o *oneOfMatcher) Name() string {
+	return o.o.name
+}
+
+func (o *oneOfMatcher) Describe() string {
+	return "oneOf"
+}
+
+func (o *oneOfMatcher) Children() []Matcher {
+	return o.words
+}
+
+func (om *onceMatcher) Name() string {
+	return om.o.name
+}
+
+func (om *onceMatcher) Describe() string {
+	return "once"
+}
+
+func (om *onceMatcher) Children() []Matcher {
+	return []Matcher{om.matcher}
+}
+
+func (m *anyOrderDictMatcher) Name() string {
+	return m.o.name
+}
+
+func (m *anyOrderDictMatcher) Describe() string {
+	return "anyOrder " + m.expected()
+}
+
+func (rr *tryAllMatcher) Name() string {
+	return rr.o.name
+}
+
+func (rr *tryAllMatcher) Describe() string {
+	return "tryAll"
+}
+
+func (rr *tryAllMatcher) Children() []Matcher {
+	return rr.nodes
+}
+
+func (p *permutationMatcher) Name() string {
+	return p.o.name
+}
+
+func (p *permutationMatcher) Describe() string {
+	return fmt.Sprintf("permutation(required=%d, optional=%d)", len(p.required), len(p.optional))
+}
+
+func (p *permutationMatcher) Children() []Matcher {
+	children := make([]Matcher, 0, len(p.required)+len(p.optional))
+	return append(append(children, p.required...), p.optional...)
+}
+
+func (a *actionMatcher) Name() string {
+	return a.o.name
+}
+
+func (a *actionMatcher) Describe() string {
+	return "action"
+}
+
+func (a *actionMatcher) Children() []Matcher {
+	return []Matcher{a.matcher}
+}
+
+func (g *guardMatcher) Name() string {
+	return g.o.name
+}
+
+func (g *guardMatcher) Describe() string {
+	return "guard"
+}
+
+func (g *guardMatcher) Children() []Matcher {
+	return []Matcher{g.matcher}
+}
+
+func (c *captureMatcher) Name() string {
+	return c.o.name
+}
+
+func (c *captureMatcher) Describe() string {
+	return "capture " + c.expected()
+}
+
+func (r *regexpMatcher) Name() string {
+	return r.o.name
+}
+
+func (r *regexpMatcher) Describe() string {
+	return "regexp " + r.expected()
+}
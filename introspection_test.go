@@ -0,0 +1,98 @@
+package context_free_grammar
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type labelCollector struct {
+	labels []string
+	depth  int
+}
+
+func (c *labelCollector) Visit(m Matcher) Visitor {
+	if m == nil {
+		c.depth--
+		return nil
+	}
+	c.labels = append(c.labels, strings.Repeat("  ", c.depth)+Label(m))
+	c.depth++
+	return c
+}
+
+func TestWalk(t *testing.T) {
+	sequence := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("lorem"),
+		NewAllowedWordMatcher("ipsum"),
+	}, WithName("lorem ipsum"))
+	root := NewFullTextMatcher([]Matcher{
+		NewAllowedWordMatcher("allowed"),
+		NewOnceMatcher(NewOneOfMatcher([]Matcher{
+			sequence,
+			NewAllowedWordMatcher("lorem"),
+		})),
+		NewDictMatcher(map[string][]ValueID{"abra": {1}}, 100500, WithName("magic")),
+		NewAllowedWordsMatcher([]string{"matcher", "awesome"}),
+		NewTryAllMatcher([]Matcher{
+			NewAnyOrderDictMatcher(map[string][]ValueID{"снять": {1}}, 1),
+		}),
+		NewPermutationMatcher(nil, []Matcher{NewAllowedWordMatcher("dolor")}),
+	}, WithName("root"))
+
+	collector := &labelCollector{}
+	Walk(collector, root)
+
+	expected := []string{
+		"root: fullText",
+		`  "allowed"`,
+		"  once",
+		"    oneOf",
+		"      lorem ipsum: sequence",
+		`        "lorem"`,
+		`        "ipsum"`,
+		`      "lorem"`,
+		"  magic: dictionary(attribute=100500)",
+		`  words("awesome", "matcher")`,
+		"  tryAll",
+		"    anyOrder dictionary(attribute=1)",
+		"  permutation(required=0, optional=1)",
+		`    "dolor"`,
+	}
+	require.Equal(t, expected, collector.labels)
+	require.Equal(t, 0, collector.depth)
+}
+
+func TestInspect_SkipChildren(t *testing.T) {
+	root := NewSequenceMatcher([]Matcher{
+		NewOnceMatcher(NewAllowedWordMatcher("lorem")),
+		NewAllowedWordMatcher("ipsum"),
+	})
+
+	var visited []string
+	Inspect(root, func(m Matcher) bool {
+		if m == nil {
+			return false
+		}
+		visited = append(visited, Label(m))
+		_, isOnce := m.(*onceMatcher)
+		return !isOnce
+	})
+	require.Equal(t, []string{"sequence", "once", `"ipsum"`}, visited)
+}
+
+func TestWalkPaths(t *testing.T) {
+	shared := NewAllowedWordMatcher("lorem")
+	once := NewOnceMatcher(shared)
+	root := NewSequenceMatcher([]Matcher{once, NewAllowedWordMatcher("ipsum"), shared})
+
+	var paths []string
+	walkPaths(root, func(m, parent Matcher, path string) {
+		if m == shared {
+			require.Equal(t, once, parent)
+		}
+		paths = append(paths, path)
+	})
+	require.Equal(t, []string{"sequence", "sequence/once[0]", "sequence/once[0]/word[0]", "sequence/word[1]"}, paths)
+}
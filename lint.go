@@ -0,0 +1,361 @@
+package context_free_grammar
+
+import (
+	"cmp"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode"
+)
+
+const maxAnalyzedLanguageSize = 1000
+
+type IssueCode string
+
+const (
+	IssueShadowedAlternative IssueCode = "shadowed-alternative"
+	IssueUnreachableBranch   IssueCode = "unreachable-branch"
+	IssueEmptyDictionary     IssueCode = "empty-dictionary"
+	IssueMalformedKey        IssueCode = "malformed-key"
+	IssueDuplicateKey        IssueCode = "duplicate-key"
+	IssueZeroWidthLoop       IssueCode = "zero-width-loop"
+)
+
+type Issue struct {
+	Code    IssueCode
+	Path    string
+	Matcher Matcher
+	Message string
+}
+
+func (i Issue) String() string {
+	return fmt.Sprintf("%s: %s: %s", i.Path, i.Code, i.Message)
+}
+
+type linter struct {
+	issues []Issue
+}
+
+func Lint(root Matcher) []Issue {
+	l := &linter{}
+	walkPaths(root, l.check)
+	l.checkDuplicateKeys(root)
+	return l.issues
+}
+
+func (l *linter) report(code IssueCode, path string, m Matcher, format string, args ...any) {
+	l.issues = append(l.issues, Issue{
+		Code:    code,
+		Path:    path,
+		Matcher: m,
+		Message: fmt.Sprintf(format, args...),
+	})
+}
+
+func (l *linter) check(m, parent Matcher, path string) {
+	_, composite := m.(Composite)
+	switch {
+	case !neverMatches(m):
+	case !composite:
+		l.report(IssueEmptyDictionary, path, m, "%s has no entries and never matches", matcherKind(m))
+	case parent == nil || !neverMatches(parent):
+		l.report(IssueUnreachableBranch, path, m, "%s can never match", matcherKind(m))
+	}
+
+	switch m := m.(type) {
+	case *allowedWordMatcher:
+		l.checkKey(path, m, m.word)
+	case *allowedWordsMatcher:
+		for _, word := range sortedKeys(m.words) {
+			l.checkKey(path, m, word)
+		}
+	case *dictMatcher:
+		for _, key := range dictionaryKeys(m.dict) {
+			l.checkKey(path, m, key)
+		}
+	case *anyOrderDictMatcher:
+		for _, key := range dictionaryKeys(m.dict) {
+			l.checkKey(path, m, key)
+		}
+	case *sequenceMatcher:
+		l.checkRepeatedOnce(path, m.words)
+	case *oneOfMatcher:
+		l.checkShadowedAlternatives(path, m.words)
+	case *fullTextMatcher:
+		for i, node := range m.nodes {
+			if canMatchEmpty(node) {
+				l.report(
+					IssueZeroWidthLoop,
+					path+"/"+pathSegment(node, i),
+					node,
+					"%s can match without consuming tokens and loop forever",
+					matcherKind(node),
+				)
+			}
+		}
+	}
+}
+
+func pathSegment(m Matcher, index int) string {
+	segment := matcherKind(m)
+	if n, ok := m.(Named); ok && n.Name() != "" {
+		segment = n.Name()
+	}
+	if index < 0 {
+		return segment
+	}
+	return fmt.Sprintf("%s[%d]", segment, index)
+}
+
+func (l *linter) checkKey(path string, m Matcher, key string) {
+	switch {
+	case key == "":
+		l.report(IssueMalformedKey, path, m, "empty key")
+	case strings.TrimSpace(key) != key:
+		l.report(IssueMalformedKey, path, m, "key %q has leading or trailing spaces", key)
+	case strings.Contains(key, "  "):
+		l.report(IssueMalformedKey, path, m, "key %q contains double spaces", key)
+	case strings.IndexFunc(key, unicode.IsUpper) >= 0:
+		l.report(IssueMalformedKey, path, m, "key %q contains capital letters", key)
+	case strings.IndexFunc(key, func(r rune) bool { return r != ' ' && unicode.IsSpace(r) }) >= 0:
+		l.report(IssueMalformedKey, path, m, "key %q contains whitespace other than spaces", key)
+	}
+}
+
+func (l *linter) checkDuplicateKeys(root Matcher) {
+	keyPaths := make(map[string][]string)
+	for _, dict := range CollectDictionaries(root) {
+		dict.Dictionary.Range(func(key string, _ []ValueID) bool {
+			keyPaths[key] = append(keyPaths[key], dict.Path)
+			return true
+		})
+	}
+	for _, key := range sortedKeys(keyPaths) {
+		paths := keyPaths[key]
+		if len(paths) < 2 {
+			continue
+		}
+		l.report(IssueDuplicateKey, paths[0], nil, "key %q is also defined in %s", key, strings.Join(paths[1:], ", "))
+	}
+}
+
+func (l *linter) checkRepeatedOnce(path string, children []Matcher) {
+	seen := make(map[Matcher]bool)
+	for i, child := range children {
+		if _, ok := child.(*onceMatcher); !ok {
+			continue
+		}
+		if seen[child] {
+			l.report(
+				IssueUnreachableBranch,
+				path+"/"+pathSegment(child, i),
+				child,
+				"once matcher is repeated in the sequence and can never match the second time",
+			)
+		}
+		seen[child] = true
+	}
+}
+
+func (l *linter) checkShadowedAlternatives(path string, alternatives []Matcher) {
+	for j, alternative := range alternatives {
+		if !isPure(alternative) {
+			continue
+		}
+		language, ok := finiteLanguage(alternative)
+		if !ok {
+			continue
+		}
+		var accepted [][]string
+		for _, tokens := range language {
+			if alternative.Match(NewInitialState(tokens)).HasMatch() {
+				accepted = append(accepted, tokens)
+			}
+		}
+		if len(accepted) == 0 {
+			continue
+		}
+
+		shadowedBy := -1
+		for i := 0; i < j && shadowedBy < 0; i++ {
+			if !isPure(alternatives[i]) {
+				continue
+			}
+			shadowed := true
+			for _, tokens := range accepted {
+				if !alternatives[i].Match(NewInitialState(tokens)).HasMatch() {
+					shadowed = false
+					break
+				}
+			}
+			if shadowed {
+				shadowedBy = i
+			}
+		}
+		if shadowedBy >= 0 {
+			l.report(
+				IssueShadowedAlternative,
+				path+"/"+pathSegment(alternative, j),
+				alternative,
+				"alternative is shadowed by %s which matches first",
+				pathSegment(alternatives[shadowedBy], shadowedBy),
+			)
+		}
+	}
+}
+
+func neverMatches(m Matcher) bool {
+	switch m := m.(type) {
+	case *allowedWordsMatcher:
+		return len(m.words) == 0
+	case *dictMatcher:
+		return m.dict.Len() == 0
+	case *anyOrderDictMatcher:
+		return m.dict.Len() == 0
+	case *sequenceMatcher:
+		for _, child := range m.words {
+			if neverMatches(child) {
+				return true
+			}
+		}
+		return false
+	case *onceMatcher:
+		return neverMatches(m.matcher)
+	case *actionMatcher:
+		return neverMatches(m.matcher)
+	case *guardMatcher:
+		return neverMatches(m.matcher)
+	case *permutationMatcher:
+		for _, child := range m.required {
+			if neverMatches(child) {
+				return true
+			}
+		}
+		return allNeverMatch(m.required) && allNeverMatch(m.optional)
+	case *oneOfMatcher:
+		return allNeverMatch(m.words)
+	case *tryAllMatcher:
+		return allNeverMatch(m.nodes)
+	case *fullTextMatcher:
+		return allNeverMatch(m.nodes)
+	}
+	return false
+}
+
+func allNeverMatch(matchers []Matcher) bool {
+	for _, m := range matchers {
+		if !neverMatches(m) {
+			return false
+		}
+	}
+	return true
+}
+
+func canMatchEmpty(m Matcher) bool {
+	switch m := m.(type) {
+	case *sequenceMatcher:
+		for _, child := range m.words {
+			if !canMatchEmpty(child) {
+				return false
+			}
+		}
+		return true
+	case *oneOfMatcher:
+		for _, child := range m.words {
+			if canMatchEmpty(child) {
+				return true
+			}
+		}
+	case *tryAllMatcher:
+		for _, child := range m.nodes {
+			if canMatchEmpty(child) {
+				return true
+			}
+		}
+	case *permutationMatcher:
+		for _, child := range m.Children() {
+			if canMatchEmpty(child) {
+				return true
+			}
+		}
+	case *actionMatcher:
+		return canMatchEmpty(m.matcher)
+	case *guardMatcher:
+		return canMatchEmpty(m.matcher)
+	}
+	return false
+}
+
+func isPure(m Matcher) bool {
+	switch m := m.(type) {
+	case *allowedWordMatcher, *allowedWordsMatcher, *dictMatcher, *anyOrderDictMatcher:
+		return true
+	case *sequenceMatcher, *oneOfMatcher, *tryAllMatcher, *fullTextMatcher, *permutationMatcher:
+		for _, child := range m.(Composite).Children() {
+			if !isPure(child) {
+				return false
+			}
+		}
+		return true
+	}
+	return false
+}
+
+func finiteLanguage(m Matcher) ([][]string, bool) {
+	switch m := m.(type) {
+	case *allowedWordMatcher:
+		return [][]string{{m.word}}, true
+	case *allowedWordsMatcher:
+		return splitKeys(sortedKeys(m.words))
+	case *dictMatcher:
+		return splitKeys(dictionaryKeys(m.dict))
+	case *oneOfMatcher:
+		var language [][]string
+		for _, child := range m.words {
+			childLanguage, ok := finiteLanguage(child)
+			if !ok || len(language)+len(childLanguage) > maxAnalyzedLanguageSize {
+				return nil, false
+			}
+			language = append(language, childLanguage...)
+		}
+		return language, true
+	case *sequenceMatcher:
+		language := [][]string{nil}
+		for _, child := range m.words {
+			childLanguage, ok := finiteLanguage(child)
+			if !ok || len(language)*len(childLanguage) > maxAnalyzedLanguageSize {
+				return nil, false
+			}
+			next := make([][]string, 0, len(language)*len(childLanguage))
+			for _, prefix := range language {
+				for _, suffix := range childLanguage {
+					tokens := make([]string, 0, len(prefix)+len(suffix))
+					next = append(next, append(append(tokens, prefix...), suffix...))
+				}
+			}
+			language = next
+		}
+		return language, true
+	}
+	return nil, false
+}
+
+func splitKeys(keys []string) ([][]string, bool) {
+	if len(keys) > maxAnalyzedLanguageSize {
+		return nil, false
+	}
+	language := make([][]string, 0, len(keys))
+	for _, key := range keys {
+		language = append(language, strings.Split(key, " "))
+	}
+	return language, true
+}
+
+func sortedKeys[K cmp.Ordered, V any](m map[K]V) []K {
+	keys := make([]K, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	return keys
+}
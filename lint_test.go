@@ -0,0 +1,74 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func lintCodes(issues []Issue) map[IssueCode][]string {
+	res := make(map[IssueCode][]string)
+	for _, issue := range issues {
+		res[issue.Code] = append(res[issue.Code], issue.Path)
+	}
+	return res
+}
+
+func TestLint_ShadowedAlternative(t *testing.T) {
+	lorem := NewAllowedWordMatcher("lorem")
+	sequence := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("lorem"),
+		NewAllowedWordMatcher("ipsum"),
+		NewAllowedWordMatcher("dolor"),
+	})
+
+	issues := Lint(NewOneOfMatcher([]Matcher{sequence, lorem}))
+	require.Empty(t, issues)
+
+	issues = Lint(NewOneOfMatcher([]Matcher{lorem, sequence}))
+	require.Len(t, issues, 1)
+	require.Equal(t, IssueShadowedAlternative, issues[0].Code)
+	require.Equal(t, "oneOf/sequence[1]", issues[0].Path)
+	require.Equal(t, sequence, issues[0].Matcher)
+	require.Equal(t, "oneOf/sequence[1]: shadowed-alternative: alternative is shadowed by word[0] which matches first", issues[0].String())
+}
+
+func TestLint_DictionaryIssues(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewDictMatcher(map[string][]ValueID{
+			"студия":     {0},
+			"2  комнаты": {2},
+			"Москва":     {1},
+		}, 1, WithName("rooms")),
+		NewDictMatcher(map[string][]ValueID{"студия": {3}}, 2, WithName("type")),
+		NewSequenceMatcher([]Matcher{
+			NewAllowedWordMatcher("в"),
+			NewAnyOrderDictMatcher(map[string][]ValueID{}, 3),
+		}),
+	})
+
+	codes := lintCodes(Lint(root))
+	require.Equal(t, map[IssueCode][]string{
+		IssueMalformedKey:      {"fullText/rooms[0]", "fullText/rooms[0]"},
+		IssueDuplicateKey:      {"fullText/rooms[0]"},
+		IssueEmptyDictionary:   {"fullText/sequence[2]/anyOrderDictionary[1]"},
+		IssueUnreachableBranch: {"fullText/sequence[2]"},
+	}, codes)
+}
+
+func TestLint_RepeatedOnceAndZeroWidthLoop(t *testing.T) {
+	once := NewOnceMatcher(NewAllowedWordMatcher("lorem"))
+	root := NewFullTextMatcher([]Matcher{
+		NewSequenceMatcher([]Matcher{once, once}),
+		NewOneOfMatcher([]Matcher{
+			NewAllowedWordMatcher("ipsum"),
+			NewSequenceMatcher(nil),
+		}),
+	})
+
+	codes := lintCodes(Lint(root))
+	require.Equal(t, map[IssueCode][]string{
+		IssueUnreachableBranch: {"fullText/sequence[0]/once[1]"},
+		IssueZeroWidthLoop:     {"fullText/oneOf[1]"},
+	}, codes)
+}
@@ -0,0 +1,83 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"slices"
+)
+
+type MergePolicy int
+
+const (
+	// MergeAppend keeps every written value in match order, the default.
+	MergeAppend MergePolicy = iota
+	// MergeUnion keeps distinct values sorted in ascending order.
+	MergeUnion
+	MergeLastWins
+	MergeFirstWins
+	// MergeError rejects a write that brings values different from the already stored ones.
+	MergeError
+)
+
+func (p MergePolicy) String() string {
+	switch p {
+	case MergeAppend:
+		return "append"
+	case MergeUnion:
+		return "union"
+	case MergeLastWins:
+		return "last-wins"
+	case MergeFirstWins:
+		return "first-wins"
+	case MergeError:
+		return "error"
+	}
+	return fmt.Sprintf("MergePolicy(%d)", int(p))
+}
+
+type MergeConflictError struct {
+	Attribute AttributeID
+	Existing  []ValueID
+	Added     []ValueID
+}
+
+func (e *MergeConflictError) Error() string {
+	return fmt.Sprintf("attribute %d already has values %v, got %v", e.Attribute, e.Existing, e.Added)
+}
+
+// WithMergePolicies sets how values written to the same attribute are combined during the parse.
+// Attributes without a policy use MergeAppend.
+func WithMergePolicies(policies map[AttributeID]MergePolicy) StateOption {
+	return func(ctx *parseContext) {
+		ctx.mergePolicies = policies
+	}
+}
+
+// Add never modifies stored slices in place, since copied states share them.
+func (m *memoryState) Add(attributeId AttributeID, valueIds ...ValueID) error {
+	existing := m.dict[attributeId]
+	switch m.policies[attributeId] {
+	case MergeAppend:
+		m.dict[attributeId] = append(existing[:len(existing):len(existing)], valueIds...)
+	case MergeUnion:
+		merged := append(existing[:len(existing):len(existing)], valueIds...)
+		slices.Sort(merged)
+		m.dict[attributeId] = slices.Compact(merged)
+	case MergeLastWins:
+		m.dict[attributeId] = append([]ValueID(nil), valueIds...)
+	case MergeFirstWins:
+		if len(existing) == 0 {
+			m.dict[attributeId] = append([]ValueID(nil), valueIds...)
+		}
+	case MergeError:
+		if len(existing) == 0 {
+			m.dict[attributeId] = append([]ValueID(nil), valueIds...)
+			return nil
+		}
+		for _, valueId := range valueIds {
+			if !slices.Contains(existing, valueId) {
+				return &MergeConflictError{attributeId, existing, valueIds}
+			}
+		}
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestMergePolicies(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewDictMatcher(map[string][]ValueID{"двушка": {2}, "2к": {2}, "3к": {3}, "студия": {0}}, 1),
+	})
+
+	for _, tc := range []struct {
+		policy   MergePolicy
+		query    string
+		expected AttrValues
+	}{
+		{MergeAppend, "3к двушка 2к", AttrValues{1: {3, 2, 2}}},
+		{MergeUnion, "3к двушка 2к", AttrValues{1: {2, 3}}},
+		{MergeLastWins, "3к двушка студия", AttrValues{1: {0}}},
+		{MergeFirstWins, "3к двушка студия", AttrValues{1: {3}}},
+		{MergeError, "двушка 2к", AttrValues{1: {2}}},
+	} {
+		t.Run(tc.policy.String(), func(t *testing.T) {
+			res := root.Match(NewInitialState(
+				getTokens(tc.query),
+				WithMergePolicies(map[AttributeID]MergePolicy{1: tc.policy}),
+			))
+			testPositiveParse(t, res)
+			testDictParserResult(t, res, tc.expected)
+		})
+	}
+
+	res := root.Match(NewInitialState(getTokens("двушка 3к"), WithMergePolicies(map[AttributeID]MergePolicy{1: MergeError})))
+	testNegativeParse(t, res)
+	require.Equal(t, []MergeConflict{
+		{Position: 1, Err: &MergeConflictError{1, []ValueID{2}, []ValueID{3}}},
+	}, res.Diagnostics().Conflicts)
+	require.Equal(t, "merge conflict at position 1: attribute 1 already has values [2], got [3]", res.Diagnostics().String())
+
+	anyOrder := NewAnyOrderDictMatcher(map[string][]ValueID{"москва": {77}, "питер": {78}}, 2)
+	res = NewSequenceMatcher([]Matcher{anyOrder, anyOrder}).Match(NewInitialState(
+		getTokens("москва дом питер"),
+		WithMergePolicies(map[AttributeID]MergePolicy{2: MergeError}),
+	))
+	testNegativeParse(t, res)
+	require.Equal(t, "merge conflict at position 2: attribute 2 already has values [77], got [78]", res.Diagnostics().String())
+}
+
+func TestMemoryState_AddDoesNotShareValues(t *testing.T) {
+	state := NewInitialState(nil, WithMergePolicies(map[AttributeID]MergePolicy{2: MergeError}))
+	require.NoError(t, state.Memory().Add(1, 2, 3))
+
+	left, right := Copy(state), Copy(state)
+	require.NoError(t, left.Memory().Add(1, 4))
+	require.NoError(t, right.Memory().Add(1, 5))
+	require.Equal(t, AttrValues{1: {2, 3, 4}}, left.Memory().GetStorage())
+	require.Equal(t, AttrValues{1: {2, 3, 5}}, right.Memory().GetStorage())
+
+	require.NoError(t, left.Memory().Add(2, 77))
+	err := left.Memory().Add(2, 78)
+	require.Equal(t, &MergeConflictError{2, []ValueID{77}, []ValueID{78}}, err)
+	require.EqualError(t, err, "attribute 2 already has values [77], got [78]")
+	require.Equal(t, []ValueID{77}, left.Memory().GetStorage()[2])
+}
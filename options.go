@@ -3,6 +3,13 @@ package context_free_grammar
 type options struct {
 	keepMatchedTokens     bool
 	calculateNeedleLength bool
+	skipUnknownTokens     bool
+	maxSkippedRatio       float64
+	name                  string
+	maxTokens             int
+	stopWords             map[string]struct{}
+	stopAt                Matcher
+	prefixLastToken       bool
 }
 
 type Option func(opt *options)
@@ -19,3 +26,54 @@ func CalculateNeedleLength() Option {
 	}
 }
 
+func SkipUnknownTokens(maxSkippedRatio float64) Option {
+	return func(opt *options) {
+		opt.skipUnknownTokens = true
+		opt.maxSkippedRatio = maxSkippedRatio
+	}
+}
+
+func WithName(name string) Option {
+	return func(opt *options) {
+		opt.name = name
+	}
+}
+
+func MaxTokens(maxTokens int) Option {
+	return func(opt *options) {
+		opt.maxTokens = maxTokens
+	}
+}
+
+func StopWords(words ...string) Option {
+	return func(opt *options) {
+		if opt.stopWords == nil {
+			opt.stopWords = make(map[string]struct{}, len(words))
+		}
+		for _, word := range words {
+			opt.stopWords[word] = struct{}{}
+		}
+	}
+}
+
+func StopAt(matcher Matcher) Option {
+	return func(opt *options) {
+		opt.stopAt = matcher
+	}
+}
+
+// PrefixLastToken lets dictionary matchers treat the last input token as partially typed:
+// when no key matches exactly, keys starting with it are reported as state completions.
+func PrefixLastToken() Option {
+	return func(opt *options) {
+		opt.prefixLastToken = true
+	}
+}
+
+type StateOption func(ctx *parseContext)
+
+func WithTracer(tracer Tracer) StateOption {
+	return func(ctx *parseContext) {
+		ctx.tracer = tracer
+	}
+}
@@ -0,0 +1,54 @@
+package context_free_grammar
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestRegexpMatcher(t *testing.T) {
+	const attrArea, attrPostalCode, attrModel AttributeID = 50, 51, 52
+	area := NewRegexpMatcher(regexp.MustCompile(`(?P<area>\d+) ?(м2|кв\.? ?м)`), map[string]RegexpGroup{"area": {Attribute: attrArea, Type: ValueInteger}}, MaxTokens(3))
+	postalCode := NewRegexpMatcher(regexp.MustCompile(`(?P<code>\d{6})`), map[string]RegexpGroup{"code": {Attribute: attrPostalCode, Type: ValueText}})
+	model := NewRegexpMatcher(regexp.MustCompile(`[ABab](?P<model>\d{3})|x`), map[string]RegexpGroup{"model": {Attribute: attrModel}}, KeepMatchedTokens())
+	root := NewFullTextMatcher([]Matcher{area, postalCode, model}, KeepMatchedTokens())
+
+	res := root.Match(NewInitialState(getTokens("50м2 101000 A320")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrArea: {50}, attrModel: {320}})
+	require.Equal(t, AttrTexts{attrPostalCode: {"101000"}}, res.Memory().GetTexts())
+	require.Equal(t, []string{"A320"}, res.MatchedTokens())
+
+	res = root.Match(NewInitialState(getTokens("65 кв м 012345 x")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrArea: {65}})
+	require.Equal(t, AttrTexts{attrPostalCode: {"012345"}}, res.Memory().GetTexts())
+	require.Equal(t, []string{"x"}, res.MatchedTokens())
+
+	res = root.Match(NewInitialState(getTokens("50м2 A3200")))
+	testNegativeParse(t, res)
+	require.Equal(
+		t,
+		`unexpected "A3200" at position 1, expected /(?P<area>\d+) ?(м2|кв\.? ?м)/, /(?P<code>\d{6})/, /[ABab](?P<model>\d{3})|x/`,
+		res.Diagnostics().String(),
+	)
+
+	number := NewRegexpMatcher(
+		regexp.MustCompile(`((?P<unit>\S+) )?(?P<number>\S+)`),
+		map[string]RegexpGroup{"unit": {Attribute: attrModel, Type: ValueText}, "number": {Attribute: attrArea, Type: ValueInteger}},
+		MaxTokens(2),
+	)
+	res = number.Match(NewInitialState(getTokens("соток 007")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{attrArea: {7}})
+	require.Equal(t, AttrTexts{attrModel: {"соток"}}, res.Memory().GetTexts())
+
+	res = number.Match(NewInitialState(getTokens("7 соток")))
+	require.True(t, res.HasMatch())
+	testDictParserResult(t, res, AttrValues{attrArea: {7}})
+	require.Empty(t, res.Memory().GetTexts())
+	require.Equal(t, []string{"соток"}, res.RemainingTokens())
+
+	testNegativeParse(t, number.Match(NewInitialState(getTokens("семь"))))
+}
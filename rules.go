@@ -0,0 +1,154 @@
+package context_free_grammar
+
+import (
+	"errors"
+	"fmt"
+	"slices"
+)
+
+const maxRulePasses = 100
+
+var ErrRulesDiverge = errors.New("rules do not converge")
+
+type Condition func(memory AttrValues) bool
+
+func Has(attributeId AttributeID) Condition {
+	return func(memory AttrValues) bool {
+		return len(memory[attributeId]) > 0
+	}
+}
+
+func HasValue(attributeId AttributeID, valueId ValueID) Condition {
+	return func(memory AttrValues) bool {
+		return slices.Contains(memory[attributeId], valueId)
+	}
+}
+
+func Not(condition Condition) Condition {
+	return func(memory AttrValues) bool {
+		return !condition(memory)
+	}
+}
+
+func All(conditions ...Condition) Condition {
+	return func(memory AttrValues) bool {
+		for _, condition := range conditions {
+			if !condition(memory) {
+				return false
+			}
+		}
+		return true
+	}
+}
+
+func Any(conditions ...Condition) Condition {
+	return func(memory AttrValues) bool {
+		for _, condition := range conditions {
+			if condition(memory) {
+				return true
+			}
+		}
+		return false
+	}
+}
+
+type Rule struct {
+	Name string
+	When Condition
+	Add  AttrValues
+	// Remove drops the listed values, an attribute with no values listed is removed entirely.
+	Remove AttrValues
+}
+
+type Derivation struct {
+	Rule      string
+	Attribute AttributeID
+	Value     ValueID
+	Removed   bool
+}
+
+func (d Derivation) String() string {
+	action := "added"
+	if d.Removed {
+		action = "removed"
+	}
+	return fmt.Sprintf("%s: %s %d:%d", d.Rule, action, d.Attribute, d.Value)
+}
+
+type Inference struct {
+	Memory AttrValues
+	// Derivations lists every change made by the rules in order.
+	Derivations []Derivation
+}
+
+// Source returns the rule which inferred the value, or false if the value came from the parse.
+func (inf *Inference) Source(attributeId AttributeID, valueId ValueID) (string, bool) {
+	if !slices.Contains(inf.Memory[attributeId], valueId) {
+		return "", false
+	}
+	for i := len(inf.Derivations) - 1; i >= 0; i-- {
+		d := inf.Derivations[i]
+		if d.Attribute == attributeId && d.Value == valueId && !d.Removed {
+			return d.Rule, true
+		}
+	}
+	return "", false
+}
+
+// ApplyRules runs the rules in order over a copy of the memory until none of them changes it.
+func ApplyRules(memory AttrValues, rules []Rule) (*Inference, error) {
+	inf := &Inference{Memory: make(AttrValues, len(memory))}
+	for attributeId, valueIds := range memory {
+		inf.Memory[attributeId] = append([]ValueID(nil), valueIds...)
+	}
+
+	for pass := 0; pass < maxRulePasses; pass++ {
+		changed := false
+		for _, rule := range rules {
+			if rule.When != nil && !rule.When(inf.Memory) {
+				continue
+			}
+			if inf.apply(rule) {
+				changed = true
+			}
+		}
+		if !changed {
+			return inf, nil
+		}
+	}
+	return inf, fmt.Errorf("%w after %d passes", ErrRulesDiverge, maxRulePasses)
+}
+
+func (inf *Inference) apply(rule Rule) bool {
+	changed := false
+	for _, attributeId := range sortedKeys(rule.Remove) {
+		valueIds := rule.Remove[attributeId]
+		if len(valueIds) == 0 {
+			valueIds = inf.Memory[attributeId]
+		}
+		for _, valueId := range slices.Clone(valueIds) {
+			if !slices.Contains(inf.Memory[attributeId], valueId) {
+				continue
+			}
+			inf.Memory[attributeId] = slices.DeleteFunc(inf.Memory[attributeId], func(v ValueID) bool {
+				return v == valueId
+			})
+			inf.Derivations = append(inf.Derivations, Derivation{rule.Name, attributeId, valueId, true})
+			changed = true
+		}
+		if len(inf.Memory[attributeId]) == 0 {
+			delete(inf.Memory, attributeId)
+		}
+	}
+	for _, attributeId := range sortedKeys(rule.Add) {
+		for _, valueId := range rule.Add[attributeId] {
+			if slices.Contains(inf.Memory[attributeId], valueId) {
+				continue
+			}
+			inf.Memory[attributeId] = append(inf.Memory[attributeId], valueId)
+			inf.Derivations = append(inf.Derivations, Derivation{rule.Name, attributeId, valueId, false})
+			changed = true
+		}
+	}
+	return changed
+}
@@ -0,0 +1,63 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	attrRooms  AttributeID = 10
+	attrMarket AttributeID = 11
+	attrMetro  AttributeID = 12
+	attrStudio AttributeID = 13
+)
+
+func TestApplyRules(t *testing.T) {
+	rules := []Rule{
+		{Name: "studio", When: Has(attrStudio), Add: AttrValues{attrRooms: {0}}, Remove: AttrValues{attrStudio: nil}},
+		{Name: "metro city", When: HasValue(attrMetro, 12), Add: AttrValues{attrCity: {77}}},
+		{Name: "city region", When: HasValue(attrCity, 77), Add: AttrValues{attrRegion: {50}}},
+		{
+			Name: "secondary",
+			When: All(Has(attrRooms), Not(Has(attrMarket)), Not(Any(HasValue(attrRooms, 0), HasValue(attrRooms, 5)))),
+			Add:  AttrValues{attrMarket: {2}},
+		},
+	}
+	root := NewFullTextMatcher([]Matcher{
+		NewDictMatcher(map[string][]ValueID{"студия": {1}, "2к": {2}}, attrStudio),
+		NewDictMatcher(map[string][]ValueID{"арбатская": {12}}, attrMetro),
+	})
+
+	res := root.Match(NewInitialState(getTokens("студия арбатская")))
+	testPositiveParse(t, res)
+	inf, err := ApplyRules(res.Memory().GetStorage(), rules)
+	require.NoError(t, err)
+	require.Equal(t, AttrValues{attrRooms: {0}, attrMetro: {12}, attrCity: {77}, attrRegion: {50}}, inf.Memory)
+	require.Equal(t, AttrValues{attrStudio: {1}, attrMetro: {12}}, res.Memory().GetStorage())
+
+	var derivations []string
+	for _, d := range inf.Derivations {
+		derivations = append(derivations, d.String())
+	}
+	require.Equal(t, []string{
+		"studio: removed 13:1",
+		"studio: added 10:0",
+		"metro city: added 2:77",
+		"city region: added 1:50",
+	}, derivations)
+
+	rule, ok := inf.Source(attrRegion, 50)
+	require.True(t, ok)
+	require.Equal(t, "city region", rule)
+	_, ok = inf.Source(attrMetro, 12)
+	require.False(t, ok)
+}
+
+func TestApplyRules_Diverge(t *testing.T) {
+	_, err := ApplyRules(AttrValues{}, []Rule{
+		{Name: "add", When: Not(Has(1)), Add: AttrValues{1: {1}}},
+		{Name: "remove", When: Has(1), Remove: AttrValues{1: {1}}},
+	})
+	require.ErrorIs(t, err, ErrRulesDiverge)
+}
@@ -0,0 +1,295 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+type ValueType int
+
+const (
+	ValueEnum ValueType = iota
+	ValueInteger
+	// ValueText attributes hold captured text instead of value ids.
+	ValueText
+)
+
+func (t ValueType) String() string {
+	switch t {
+	case ValueEnum:
+		return "enum"
+	case ValueInteger:
+		return "integer"
+	case ValueText:
+		return "text"
+	}
+	return fmt.Sprintf("ValueType(%d)", int(t))
+}
+
+type Cardinality int
+
+const (
+	Single Cardinality = iota
+	Multi
+)
+
+func (c Cardinality) String() string {
+	switch c {
+	case Single:
+		return "single"
+	case Multi:
+		return "multi"
+	}
+	return fmt.Sprintf("Cardinality(%d)", int(c))
+}
+
+type AttributeSchema struct {
+	ID          AttributeID
+	Name        string
+	Type        ValueType
+	Cardinality Cardinality
+	Merge       MergePolicy
+	// Values lists allowed enum values with their display names, any value is allowed when empty.
+	Values map[ValueID]string
+}
+
+func (a *AttributeSchema) allows(valueId ValueID) bool {
+	if a.Type != ValueEnum || len(a.Values) == 0 {
+		return true
+	}
+	_, ok := a.Values[valueId]
+	return ok
+}
+
+func (a *AttributeSchema) formatValue(valueId ValueID) string {
+	if name, ok := a.Values[valueId]; ok && a.Type == ValueEnum {
+		return name
+	}
+	return strconv.FormatInt(valueId, 10)
+}
+
+type Schema struct {
+	attributes map[AttributeID]*AttributeSchema
+	byName     map[string]*AttributeSchema
+}
+
+func NewSchema(attributes ...AttributeSchema) (*Schema, error) {
+	s := &Schema{
+		attributes: make(map[AttributeID]*AttributeSchema, len(attributes)),
+		byName:     make(map[string]*AttributeSchema, len(attributes)),
+	}
+	for i := range attributes {
+		attribute := &attributes[i]
+		if attribute.Name == "" {
+			return nil, fmt.Errorf("schema: attribute %d has no name", attribute.ID)
+		}
+		if _, ok := s.attributes[attribute.ID]; ok {
+			return nil, fmt.Errorf("schema: duplicate attribute %d", attribute.ID)
+		}
+		if _, ok := s.byName[attribute.Name]; ok {
+			return nil, fmt.Errorf("schema: duplicate attribute name %q", attribute.Name)
+		}
+		s.attributes[attribute.ID] = attribute
+		s.byName[attribute.Name] = attribute
+	}
+	return s, nil
+}
+
+func (s *Schema) Attribute(attributeId AttributeID) (AttributeSchema, bool) {
+	attribute, ok := s.attributes[attributeId]
+	if !ok {
+		return AttributeSchema{}, false
+	}
+	return *attribute, true
+}
+
+func (s *Schema) AttributeByName(name string) (AttributeSchema, bool) {
+	attribute, ok := s.byName[name]
+	if !ok {
+		return AttributeSchema{}, false
+	}
+	return *attribute, true
+}
+
+// MergePolicies returns the declared policies to be passed to WithMergePolicies.
+func (s *Schema) MergePolicies() map[AttributeID]MergePolicy {
+	policies := make(map[AttributeID]MergePolicy, len(s.attributes))
+	for attributeId, attribute := range s.attributes {
+		policies[attributeId] = attribute.Merge
+	}
+	return policies
+}
+
+// Cardinalities returns the declared cardinalities to be passed to Taxonomy.Contradictions.
+func (s *Schema) Cardinalities() map[AttributeID]Cardinality {
+	cardinalities := make(map[AttributeID]Cardinality, len(s.attributes))
+	for attributeId, attribute := range s.attributes {
+		cardinalities[attributeId] = attribute.Cardinality
+	}
+	return cardinalities
+}
+
+const (
+	IssueUnknownAttribute IssueCode = "unknown-attribute"
+	IssueUnknownValue     IssueCode = "unknown-value"
+	IssueCardinality      IssueCode = "cardinality"
+	IssueTypeMismatch     IssueCode = "type-mismatch"
+)
+
+// ValidateGrammar checks that every dictionary writes known attributes and values
+// and never puts several values into a single-valued attribute with one key.
+// Captured text and regexp groups must match the type of their attributes.
+func (s *Schema) ValidateGrammar(root Matcher) []Issue {
+	var issues []Issue
+	walkPaths(root, func(m, _ Matcher, path string) {
+		switch m := m.(type) {
+		case *captureMatcher:
+			issues = s.checkText(issues, m, path, m.attributeId, ValueText, "captures text into")
+		case *regexpMatcher:
+			for _, name := range sortedKeys(m.groups) {
+				group := m.groups[name]
+				issues = s.checkText(issues, m, path, group.Attribute, group.Type, fmt.Sprintf("group %q writes %s into", name, group.Type))
+			}
+		}
+	})
+
+	for _, dict := range CollectDictionaries(root) {
+		attribute, ok := s.attributes[dict.Attribute]
+		if !ok {
+			issues = append(issues, Issue{
+				Code:    IssueUnknownAttribute,
+				Path:    dict.Path,
+				Matcher: dict.Matcher,
+				Message: fmt.Sprintf("attribute %d is not in the schema", dict.Attribute),
+			})
+			continue
+		}
+		if attribute.Type == ValueText {
+			issues = append(issues, Issue{
+				Code:    IssueTypeMismatch,
+				Path:    dict.Path,
+				Matcher: dict.Matcher,
+				Message: fmt.Sprintf("writes value ids into text attribute %s", attribute.Name),
+			})
+			continue
+		}
+		for _, key := range dictionaryKeys(dict.Dictionary) {
+			valueIds, _ := dict.Dictionary.Lookup(key)
+			for _, valueId := range valueIds {
+				if !attribute.allows(valueId) {
+					issues = append(issues, Issue{
+						Code:    IssueUnknownValue,
+						Path:    dict.Path,
+						Matcher: dict.Matcher,
+						Message: fmt.Sprintf("key %q maps to unknown %s value %d", key, attribute.Name, valueId),
+					})
+				}
+			}
+			if attribute.Cardinality == Single && len(uniqueValues(valueIds)) > 1 {
+				issues = append(issues, Issue{
+					Code:    IssueCardinality,
+					Path:    dict.Path,
+					Matcher: dict.Matcher,
+					Message: fmt.Sprintf("key %q maps to several values of single-valued %s", key, attribute.Name),
+				})
+			}
+		}
+	}
+	return issues
+}
+
+// checkText reports writes of text into attributes holding value ids and the other way round.
+func (s *Schema) checkText(issues []Issue, m Matcher, path string, attributeId AttributeID, valueType ValueType, action string) []Issue {
+	attribute, ok := s.attributes[attributeId]
+	switch {
+	case !ok:
+		issues = append(issues, Issue{
+			Code:    IssueUnknownAttribute,
+			Path:    path,
+			Matcher: m,
+			Message: fmt.Sprintf("attribute %d is not in the schema", attributeId),
+		})
+	case (attribute.Type == ValueText) != (valueType == ValueText):
+		issues = append(issues, Issue{
+			Code:    IssueTypeMismatch,
+			Path:    path,
+			Matcher: m,
+			Message: fmt.Sprintf("%s %s attribute %s", action, attribute.Type, attribute.Name),
+		})
+	}
+	return issues
+}
+
+type Violation struct {
+	Attribute AttributeID
+	Values    []ValueID
+	Message   string
+}
+
+func (v Violation) String() string {
+	return v.Message
+}
+
+// Check validates parse results against the schema.
+func (s *Schema) Check(memory AttrValues) []Violation {
+	var violations []Violation
+	for _, attributeId := range sortedKeys(memory) {
+		valueIds := memory[attributeId]
+		attribute, ok := s.attributes[attributeId]
+		if !ok {
+			violations = append(violations, Violation{
+				attributeId,
+				valueIds,
+				fmt.Sprintf("attribute %d is not in the schema", attributeId),
+			})
+			continue
+		}
+		var unknown []ValueID
+		for _, valueId := range valueIds {
+			if !attribute.allows(valueId) {
+				unknown = append(unknown, valueId)
+			}
+		}
+		if len(unknown) > 0 {
+			violations = append(violations, Violation{
+				attributeId,
+				unknown,
+				fmt.Sprintf("%s has unknown values %v", attribute.Name, unknown),
+			})
+		}
+		if unique := uniqueValues(valueIds); attribute.Cardinality == Single && len(unique) > 1 {
+			violations = append(violations, Violation{
+				attributeId,
+				unique,
+				fmt.Sprintf("single-valued %s has %d values", attribute.Name, len(unique)),
+			})
+		}
+	}
+	return violations
+}
+
+// Format prints the memory with attribute and value names, e.g. "city: Москва; rooms: 2, 3".
+func (s *Schema) Format(memory AttrValues) string {
+	parts := make([]string, 0, len(memory))
+	for _, attributeId := range sortedKeys(memory) {
+		attribute, ok := s.attributes[attributeId]
+		if !ok {
+			attribute = &AttributeSchema{Name: strconv.FormatInt(attributeId, 10), Type: ValueInteger}
+		}
+		values := make([]string, 0, len(memory[attributeId]))
+		for _, valueId := range memory[attributeId] {
+			values = append(values, attribute.formatValue(valueId))
+		}
+		parts = append(parts, attribute.Name+": "+strings.Join(values, ", "))
+	}
+	return strings.Join(parts, "; ")
+}
+
+func uniqueValues(valueIds []ValueID) []ValueID {
+	unique := make(map[ValueID]bool, len(valueIds))
+	for _, valueId := range valueIds {
+		unique[valueId] = true
+	}
+	return sortedKeys(unique)
+}
@@ -0,0 +1,97 @@
+package context_free_grammar
+
+import (
+	"regexp"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func testSchema(t *testing.T) *Schema {
+	schema, err := NewSchema(
+		AttributeSchema{ID: 1, Name: "rooms", Type: ValueInteger, Cardinality: Multi},
+		AttributeSchema{ID: 2, Name: "city", Cardinality: Single, Values: map[ValueID]string{77: "Москва", 78: "Санкт-Петербург"}},
+	)
+	require.NoError(t, err)
+	return schema
+}
+
+func TestNewSchema_Duplicates(t *testing.T) {
+	_, err := NewSchema(AttributeSchema{ID: 1, Name: "rooms"}, AttributeSchema{ID: 1, Name: "city"})
+	require.EqualError(t, err, "schema: duplicate attribute 1")
+	_, err = NewSchema(AttributeSchema{ID: 1, Name: "rooms"}, AttributeSchema{ID: 2, Name: "rooms"})
+	require.EqualError(t, err, `schema: duplicate attribute name "rooms"`)
+}
+
+func TestSchema_ValidateGrammar(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewDictMatcher(map[string][]ValueID{"2к": {2}, "3к": {3}}, 1),
+		NewDictMatcher(map[string][]ValueID{"москва": {77}, "мск": {77, 50}, "казань": {16}}, 2, WithName("city")),
+		NewAnyOrderDictMatcher(map[string][]ValueID{"новостройка": {1}}, 100500),
+	})
+
+	var messages []string
+	for _, issue := range testSchema(t).ValidateGrammar(root) {
+		messages = append(messages, issue.String())
+	}
+	require.Equal(t, []string{
+		`fullText/city[1]: unknown-value: key "казань" maps to unknown city value 16`,
+		`fullText/city[1]: unknown-value: key "мск" maps to unknown city value 50`,
+		`fullText/city[1]: cardinality: key "мск" maps to several values of single-valued city`,
+		`fullText/anyOrderDictionary[2]: unknown-attribute: attribute 100500 is not in the schema`,
+	}, messages)
+}
+
+func TestSchema_CheckAndFormat(t *testing.T) {
+	schema := testSchema(t)
+	root := NewFullTextMatcher([]Matcher{
+		NewDictMatcher(map[string][]ValueID{"2к": {2}, "3к": {3}}, 1),
+		NewDictMatcher(map[string][]ValueID{"москва": {77}, "питер": {78}}, 2),
+	})
+
+	res := root.Match(NewInitialState(getTokens("москва 2к 3к")))
+	testPositiveParse(t, res)
+	require.Empty(t, schema.Check(res.Memory().GetStorage()))
+	require.Equal(t, "rooms: 2, 3; city: Москва", schema.Format(res.Memory().GetStorage()))
+
+	res = root.Match(NewInitialState(getTokens("москва питер")))
+	require.Equal(t, []Violation{
+		{2, []ValueID{77, 78}, "single-valued city has 2 values"},
+	}, schema.Check(res.Memory().GetStorage()))
+
+	memory := AttrValues{2: {16}, 9: {1}}
+	require.Equal(t, []Violation{
+		{2, []ValueID{16}, "city has unknown values [16]"},
+		{9, []ValueID{1}, "attribute 9 is not in the schema"},
+	}, schema.Check(memory))
+	require.Equal(t, "city: 16; 9: 1", schema.Format(memory))
+}
+
+func TestSchema_ValidateGrammar_TextAttributes(t *testing.T) {
+	schema, err := NewSchema(
+		AttributeSchema{ID: 1, Name: "rooms", Type: ValueInteger},
+		AttributeSchema{ID: 40, Name: "street", Type: ValueText},
+	)
+	require.NoError(t, err)
+	root := NewSequenceMatcher([]Matcher{
+		NewCaptureMatcher(40),
+		NewCaptureMatcher(1),
+		NewDictMatcher(map[string][]ValueID{"ленина": {1}}, 40),
+		NewRegexpMatcher(regexp.MustCompile(`(?P<house>\d+)(?P<rooms>к)?`), map[string]RegexpGroup{
+			"house": {Attribute: 40},
+			"rooms": {Attribute: 1, Type: ValueText},
+		}),
+		NewRegexpMatcher(regexp.MustCompile(`(?P<street>\S+)`), map[string]RegexpGroup{"street": {Attribute: 40, Type: ValueText}}),
+	})
+
+	var messages []string
+	for _, issue := range schema.ValidateGrammar(root) {
+		messages = append(messages, issue.String())
+	}
+	require.Equal(t, []string{
+		"sequence/capture[1]: type-mismatch: captures text into integer attribute rooms",
+		`sequence/regexp[3]: type-mismatch: group "house" writes enum into text attribute street`,
+		`sequence/regexp[3]: type-mismatch: group "rooms" writes text into integer attribute rooms`,
+		"sequence/dictionary[2]: type-mismatch: writes value ids into text attribute street",
+	}, messages)
+}
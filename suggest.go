@@ -0,0 +1,315 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"sort"
+	"strings"
+)
+
+type Suggestion struct {
+	Text string
+	// Attribute and ValueIDs are the values a dictionary entry writes, both are empty for plain words.
+	Attribute AttributeID
+	ValueIDs  []ValueID
+	// Matcher is the leaf offering the suggestion.
+	Matcher Matcher
+}
+
+// Suggest returns up to limit continuations of the typed tokens which the grammar accepts,
+// ranked in grammar order. Unlike Match every alternative is explored, not only the first matching one.
+// A non-positive limit returns all of them.
+func Suggest(root Matcher, tokens []string, limit int, opts ...StateOption) []Suggestion {
+	s := &suggester{
+		limit: limit,
+		seen:  make(map[suggestionKey]bool),
+	}
+	s.advance(root, NewInitialState(tokens, opts...))
+	return s.res
+}
+
+type suggestionKey struct {
+	text        string
+	attributeId AttributeID
+}
+
+type suggester struct {
+	limit int
+	res   []Suggestion
+	seen  map[suggestionKey]bool
+}
+
+type branch struct {
+	state   MatchState
+	matched bool
+	skipped int
+}
+
+func (s *suggester) full() bool {
+	return s.limit > 0 && len(s.res) >= s.limit
+}
+
+func (s *suggester) add(suggestion Suggestion) bool {
+	key := suggestionKey{suggestion.Text, suggestion.Attribute}
+	if !s.seen[key] {
+		s.seen[key] = true
+		s.res = append(s.res, suggestion)
+	}
+	return !s.full()
+}
+
+// advance returns every state the matcher may leave after consuming a part of the input.
+// Matchers reached once the input is exhausted contribute their suggestions instead.
+func (s *suggester) advance(m Matcher, state MatchState) []MatchState {
+	if s.full() {
+		return nil
+	}
+	if len(state.RemainingTokens()) == 0 {
+		s.first(m, state)
+		return nil
+	}
+
+	switch m := m.(type) {
+	case *sequenceMatcher:
+		states := []MatchState{state}
+		for _, node := range m.words {
+			var next []MatchState
+			for _, st := range states {
+				next = append(next, s.advance(node, st)...)
+			}
+			states = dedupeStates(next)
+		}
+		return states
+	case *oneOfMatcher:
+		var res []MatchState
+		for _, node := range m.words {
+			res = append(res, s.advance(node, state)...)
+		}
+		return res
+	case *tryAllMatcher:
+		branches := []branch{{state: state}}
+		for _, node := range m.nodes {
+			for _, b := range branches {
+				for _, st := range s.advance(node, b.state) {
+					branches = append(branches, branch{state: st, matched: true})
+				}
+			}
+		}
+		var res []MatchState
+		for _, b := range branches {
+			if b.matched {
+				res = append(res, b.state)
+			}
+		}
+		return res
+	case *fullTextMatcher:
+		return s.fullText(m, state)
+	case *permutationMatcher:
+		return s.permutation(m, state)
+	case *onceMatcher:
+		if m.used(state) {
+			return nil
+		}
+		res := s.advance(m.matcher, state)
+		for i, st := range res {
+			res[i] = m.markUsed(st)
+		}
+		return res
+	case *guardMatcher:
+		if !m.condition(state.Memory().GetStorage()) {
+			return nil
+		}
+		return s.advance(m.matcher, state)
+	case *actionMatcher:
+		var res []MatchState
+		for _, st := range s.advance(m.matcher, state) {
+			if st = m.apply(state, Copy(st)); st.HasMatch() {
+				res = append(res, st)
+			}
+		}
+		return res
+	}
+
+	s.rest(m, state)
+	if res := Invoke(m, Copy(state)); res.HasMatch() {
+		return []MatchState{res}
+	}
+	return nil
+}
+
+func (s *suggester) fullText(m *fullTextMatcher, state MatchState) []MatchState {
+	inputLength := len(state.RemainingTokens())
+	var res []MatchState
+	branches := []branch{{state: state}}
+	for len(branches) > 0 {
+		var next []branch
+		for _, b := range branches {
+			tokens := b.state.RemainingTokens()
+			if len(tokens) == 0 {
+				for _, node := range m.nodes {
+					s.first(node, b.state)
+				}
+				if b.matched {
+					res = append(res, b.state)
+				}
+				continue
+			}
+			advanced := false
+			for _, node := range m.nodes {
+				for _, st := range s.advance(node, b.state) {
+					next = append(next, branch{st, true, b.skipped})
+					advanced = true
+				}
+			}
+			if advanced || !m.o.skipUnknownTokens || float64(b.skipped+1) > m.o.maxSkippedRatio*float64(inputLength) {
+				continue
+			}
+			st := derive(b.state, b.state.HasMatch(), tokens[1:], nil, b.state.Memory())
+			next = append(next, branch{st, b.matched, b.skipped + 1})
+		}
+		branches = dedupeBranches(next)
+	}
+	return res
+}
+
+func (s *suggester) permutation(m *permutationMatcher, state MatchState) []MatchState {
+	nodes := make([]Matcher, 0, len(m.required)+len(m.optional))
+	nodes = append(append(nodes, m.required...), m.optional...)
+	used := make([]bool, len(nodes))
+
+	var res []MatchState
+	var permute func(state MatchState)
+	permute = func(state MatchState) {
+		for i, node := range nodes {
+			if used[i] {
+				continue
+			}
+			for _, st := range s.advance(node, state) {
+				used[i] = true
+				permute(st)
+				used[i] = false
+			}
+		}
+		matchedAny := false
+		for i := range nodes {
+			if !used[i] && i < len(m.required) {
+				return
+			}
+			matchedAny = matchedAny || used[i]
+		}
+		if matchedAny {
+			res = append(res, state)
+		}
+	}
+	permute(state)
+	return res
+}
+
+// first records what the matcher accepts as its first tokens.
+func (s *suggester) first(m Matcher, state MatchState) {
+	if s.full() {
+		return
+	}
+	switch m := m.(type) {
+	case *allowedWordMatcher:
+		s.add(Suggestion{Text: m.word, Matcher: m})
+	case *allowedWordsMatcher:
+		for _, word := range sortedKeys(m.words) {
+			if !s.add(Suggestion{Text: word, Matcher: m}) {
+				return
+			}
+		}
+	case *dictMatcher:
+		s.dictionary(m, resolveDictionary(m.dict, state), m.attributeId, "")
+	case *anyOrderDictMatcher:
+		s.dictionary(m, resolveDictionary(m.dict, state), m.attributeId, "")
+	case *sequenceMatcher:
+		if len(m.words) > 0 {
+			s.first(m.words[0], state)
+		}
+	case *oneOfMatcher:
+		for _, node := range m.words {
+			s.first(node, state)
+		}
+	case *tryAllMatcher:
+		for _, node := range m.nodes {
+			s.first(node, state)
+		}
+	case *fullTextMatcher:
+		for _, node := range m.nodes {
+			s.first(node, state)
+		}
+	case *permutationMatcher:
+		for _, node := range append(append([]Matcher(nil), m.required...), m.optional...) {
+			s.first(node, state)
+		}
+	case *onceMatcher:
+		if !m.used(state) {
+			s.first(m.matcher, state)
+		}
+	case *guardMatcher:
+		if m.condition(state.Memory().GetStorage()) {
+			s.first(m.matcher, state)
+		}
+	case *actionMatcher:
+		s.first(m.matcher, state)
+	}
+}
+
+// rest records the remainders of multi-token keys started by the rest of the input, e.g. "петербург" after "санкт".
+func (s *suggester) rest(m Matcher, state MatchState) {
+	prefix := strings.Join(state.RemainingTokens(), " ") + " "
+	switch m := m.(type) {
+	case *allowedWordsMatcher:
+		for _, word := range sortedKeys(m.words) {
+			if strings.HasPrefix(word, prefix) && !s.add(Suggestion{Text: word[len(prefix):], Matcher: m}) {
+				return
+			}
+		}
+	case *dictMatcher:
+		s.dictionary(m, resolveDictionary(m.dict, state), m.attributeId, prefix)
+	case *anyOrderDictMatcher:
+		s.dictionary(m, resolveDictionary(m.dict, state), m.attributeId, prefix)
+	}
+}
+
+func (s *suggester) dictionary(m Matcher, dict Dictionary, attributeId AttributeID, prefix string) {
+	rangePrefix(dict, prefix, func(key string, valueIds []ValueID) bool {
+		return s.add(Suggestion{key[len(prefix):], attributeId, valueIds, m})
+	})
+}
+
+// stateKey identifies states which lead to the same suggestions.
+func stateKey(state MatchState) string {
+	var used []string
+	for om := range usedOnceOf(state) {
+		used = append(used, fmt.Sprintf("%p", om))
+	}
+	sort.Strings(used)
+	memory := state.Memory()
+	return fmt.Sprint(len(state.RemainingTokens()), memory.GetStorage(), memory.GetTexts(), used)
+}
+
+func dedupeStates(states []MatchState) []MatchState {
+	seen := make(map[string]bool, len(states))
+	res := states[:0]
+	for _, state := range states {
+		if key := stateKey(state); !seen[key] {
+			seen[key] = true
+			res = append(res, state)
+		}
+	}
+	return res
+}
+
+func dedupeBranches(branches []branch) []branch {
+	seen := make(map[string]bool, len(branches))
+	res := branches[:0]
+	for _, b := range branches {
+		key := fmt.Sprint(stateKey(b.state), b.matched, b.skipped)
+		if !seen[key] {
+			seen[key] = true
+			res = append(res, b)
+		}
+	}
+	return res
+}
@@ -0,0 +1,88 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func TestSuggest(t *testing.T) {
+	const (
+		rooms AttributeID = 1
+		city  AttributeID = 2
+		metro AttributeID = 3
+	)
+	studioMatcher := NewAllowedWordMatcher("студия")
+	roomsMatcher := NewDictMatcher(map[string][]ValueID{"однокомнатная": {1}, "двухкомнатная": {2}}, rooms)
+	cityMatcher := NewDictMatcher(map[string][]ValueID{"москва": {77}, "санкт петербург": {78}}, city)
+	root := NewFullTextMatcher([]Matcher{
+		NewOnceMatcher(NewAllowedWordsMatcher([]string{"сниму", "куплю"})),
+		NewSequenceMatcher([]Matcher{
+			NewAllowedWordMatcher("квартира"),
+			NewOneOfMatcher([]Matcher{studioMatcher, roomsMatcher}),
+		}),
+		NewOnceMatcher(cityMatcher),
+		NewGuardMatcher(Has(city), NewDictMatcher(map[string][]ValueID{"арбатская": {1}}, metro)),
+	})
+
+	require.Equal(t, []Suggestion{
+		{Text: "студия", Matcher: studioMatcher},
+		{"двухкомнатная", rooms, []ValueID{2}, roomsMatcher},
+		{"однокомнатная", rooms, []ValueID{1}, roomsMatcher},
+	}, Suggest(root, getTokens("сниму квартира"), 0))
+
+	texts := func(suggestions []Suggestion) []string {
+		var res []string
+		for _, suggestion := range suggestions {
+			res = append(res, suggestion.Text)
+		}
+		return res
+	}
+	require.Equal(t, []string{"куплю", "сниму", "квартира", "москва", "санкт петербург"}, texts(Suggest(root, nil, 0)))
+	require.Equal(t, []string{"куплю", "сниму"}, texts(Suggest(root, nil, 2)))
+	require.Equal(t, []string{"квартира", "москва", "санкт петербург"}, texts(Suggest(root, getTokens("сниму"), 0)))
+	require.Equal(t, []string{"квартира", "арбатская"}, texts(Suggest(root, getTokens("сниму москва"), 0)))
+	require.Equal(
+		t,
+		[]Suggestion{{"петербург", city, []ValueID{78}, cityMatcher}},
+		Suggest(root, getTokens("куплю санкт"), 0),
+	)
+	require.Empty(t, Suggest(root, getTokens("сдам"), 0))
+
+	res := root.Match(NewInitialState(getTokens("сниму москва")))
+	testPositiveParse(t, res)
+	require.Equal(t, []string{"квартира", "арбатская"}, texts(Suggest(root, getTokens("сниму москва"), 0)))
+}
+
+func TestSuggest_Permutation(t *testing.T) {
+	root := NewPermutationMatcher(
+		[]Matcher{NewAllowedWordMatcher("a"), NewAllowedWordMatcher("b")},
+		[]Matcher{NewAllowedWordMatcher("c")},
+	)
+	var texts []string
+	for _, suggestion := range Suggest(root, getTokens("b"), 0) {
+		texts = append(texts, suggestion.Text)
+	}
+	require.Equal(t, []string{"a", "c"}, texts)
+}
+
+func TestSuggest_OnceSuggestionsParse(t *testing.T) {
+	a := NewOnceMatcher(NewAllowedWordMatcher("A"))
+	root := NewOneOfMatcher([]Matcher{
+		NewFullTextMatcher([]Matcher{a, NewOnceMatcher(NewAllowedWordMatcher("B"))}),
+		NewFullTextMatcher([]Matcher{a, NewOnceMatcher(NewAllowedWordMatcher("C"))}),
+	})
+
+	for query, expected := range map[string][]string{
+		"A": {"B", "C"},
+		"B": {"A"},
+		"C": {"A"},
+	} {
+		var texts []string
+		for _, suggestion := range Suggest(root, getTokens(query), 0) {
+			texts = append(texts, suggestion.Text)
+			testPositiveParse(t, root.Match(NewInitialState(getTokens(query+" "+suggestion.Text))))
+		}
+		require.Equal(t, expected, texts, query)
+	}
+}
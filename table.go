@@ -0,0 +1,223 @@
+package context_free_grammar
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"sort"
+)
+
+// Dictionary table layout, little endian, offsets relative to the table start:
+//
+//	count        uint32
+//	maxKeyLength uint32
+//	keyOffsets   [count+1]uint32  byte offsets in keys
+//	valueOffsets [count+1]uint32  indexes in values
+//	keys         sorted keys, concatenated
+//	padding      up to 8 bytes alignment
+//	values       [valueOffsets[count]]int64
+const tableHeaderSize = 8
+
+// Standalone dictionary files start with the magic and version followed by the table.
+const (
+	tableMagic      = "CFGD"
+	tableVersion    = 1
+	tableFileHeader = 8
+)
+
+var errCorruptedTable = errors.New("corrupted dictionary table")
+
+type tableDictionary struct {
+	count        int
+	maxKeyLength int
+	keyOffsets   []byte
+	valueOffsets []byte
+	keys         []byte
+	values       []byte
+}
+
+func encodeDictionaryTable(dict Dictionary) []byte {
+	keys := dictionaryKeys(dict)
+	count := len(keys)
+
+	keysSize, valuesCount, maxKeyLength := 0, 0, 0
+	for _, key := range keys {
+		valueIds, _ := dict.Lookup(key)
+		keysSize += len(key)
+		valuesCount += len(valueIds)
+		maxKeyLength = max(maxKeyLength, countKeyTokens(key))
+	}
+
+	keysStart := tableHeaderSize + 8*(count+1)
+	valuesStart := align8(keysStart + keysSize)
+	data := make([]byte, valuesStart+8*valuesCount)
+
+	binary.LittleEndian.PutUint32(data, uint32(count))
+	binary.LittleEndian.PutUint32(data[4:], uint32(maxKeyLength))
+	keyOffsets := data[tableHeaderSize:]
+	valueOffsets := data[tableHeaderSize+4*(count+1):]
+	keyOffset, valueIndex := 0, 0
+	for i, key := range keys {
+		binary.LittleEndian.PutUint32(keyOffsets[4*i:], uint32(keyOffset))
+		binary.LittleEndian.PutUint32(valueOffsets[4*i:], uint32(valueIndex))
+		copy(data[keysStart+keyOffset:], key)
+		keyOffset += len(key)
+
+		valueIds, _ := dict.Lookup(key)
+		for _, valueId := range valueIds {
+			binary.LittleEndian.PutUint64(data[valuesStart+8*valueIndex:], uint64(valueId))
+			valueIndex++
+		}
+	}
+	binary.LittleEndian.PutUint32(keyOffsets[4*count:], uint32(keyOffset))
+	binary.LittleEndian.PutUint32(valueOffsets[4*count:], uint32(valueIndex))
+	return data
+}
+
+// decodeDictionaryTable validates the table and serves lookups directly from data without copying it.
+func decodeDictionaryTable(data []byte) (*tableDictionary, error) {
+	if len(data) < tableHeaderSize {
+		return nil, errCorruptedTable
+	}
+	count := int(binary.LittleEndian.Uint32(data))
+	keysStart := tableHeaderSize + 8*(count+1)
+	if keysStart > len(data) {
+		return nil, errCorruptedTable
+	}
+	d := &tableDictionary{
+		count:        count,
+		maxKeyLength: int(binary.LittleEndian.Uint32(data[4:])),
+		keyOffsets:   data[tableHeaderSize : tableHeaderSize+4*(count+1)],
+		valueOffsets: data[tableHeaderSize+4*(count+1) : keysStart],
+	}
+
+	keysSize, valuesCount := d.keyOffset(count), d.valueOffset(count)
+	valuesStart := align8(keysStart + keysSize)
+	if keysStart+keysSize > len(data) || valuesStart+8*valuesCount != len(data) {
+		return nil, errCorruptedTable
+	}
+	for i := 0; i < count; i++ {
+		if d.keyOffset(i) > d.keyOffset(i+1) || d.valueOffset(i) > d.valueOffset(i+1) {
+			return nil, errCorruptedTable
+		}
+	}
+	d.keys = data[keysStart : keysStart+keysSize]
+	d.values = data[valuesStart:]
+	return d, nil
+}
+
+func align8(n int) int {
+	return (n + 7) &^ 7
+}
+
+func (d *tableDictionary) keyOffset(i int) int {
+	return int(binary.LittleEndian.Uint32(d.keyOffsets[4*i:]))
+}
+
+func (d *tableDictionary) valueOffset(i int) int {
+	return int(binary.LittleEndian.Uint32(d.valueOffsets[4*i:]))
+}
+
+func (d *tableDictionary) key(i int) []byte {
+	return d.keys[d.keyOffset(i):d.keyOffset(i+1)]
+}
+
+func (d *tableDictionary) valueIds(i int) []ValueID {
+	from, to := d.valueOffset(i), d.valueOffset(i+1)
+	valueIds := make([]ValueID, 0, to-from)
+	for j := from; j < to; j++ {
+		valueIds = append(valueIds, ValueID(binary.LittleEndian.Uint64(d.values[8*j:])))
+	}
+	return valueIds
+}
+
+func (d *tableDictionary) Lookup(key string) ([]ValueID, bool) {
+	i := sort.Search(d.count, func(i int) bool {
+		return string(d.key(i)) >= key
+	})
+	if i == d.count || string(d.key(i)) != key {
+		return nil, false
+	}
+	return d.valueIds(i), true
+}
+
+func (d *tableDictionary) Len() int {
+	return d.count
+}
+
+func (d *tableDictionary) MaxKeyLength() int {
+	return d.maxKeyLength
+}
+
+func (d *tableDictionary) Range(fn func(key string, valueIds []ValueID) bool) {
+	for i := 0; i < d.count; i++ {
+		if !fn(string(d.key(i)), d.valueIds(i)) {
+			return
+		}
+	}
+}
+
+// WriteDictionaryTable writes the dictionary as a sorted key table to be opened with OpenMappedDictionary.
+func WriteDictionaryTable(w io.Writer, dict Dictionary) error {
+	header := make([]byte, tableFileHeader)
+	copy(header, tableMagic)
+	binary.LittleEndian.PutUint32(header[4:], tableVersion)
+	if _, err := w.Write(header); err != nil {
+		return err
+	}
+	_, err := w.Write(encodeDictionaryTable(dict))
+	return err
+}
+
+// MappedDictionary is a read-only dictionary served from a memory-mapped file,
+// so only the pages touched by lookups are resident.
+type MappedDictionary struct {
+	*tableDictionary
+	release func() error
+}
+
+func OpenMappedDictionary(path string) (*MappedDictionary, error) {
+	data, release, err := mapFile(path)
+	if err != nil {
+		return nil, err
+	}
+	table, err := decodeDictionaryFile(data)
+	if err != nil {
+		release()
+		return nil, fmt.Errorf("%s: %w", path, err)
+	}
+	return &MappedDictionary{table, release}, nil
+}
+
+func decodeDictionaryFile(data []byte) (*tableDictionary, error) {
+	if len(data) < tableFileHeader || !bytes.Equal(data[:4], []byte(tableMagic)) {
+		return nil, errors.New("not a dictionary table")
+	}
+	if version := binary.LittleEndian.Uint32(data[4:]); version != tableVersion {
+		return nil, fmt.Errorf("unsupported dictionary table version %d", version)
+	}
+	return decodeDictionaryTable(data[tableFileHeader:])
+}
+
+// Close unmaps the file, the dictionary and matchers using it must not be used afterwards.
+func (d *MappedDictionary) Close() error {
+	if d.release == nil {
+		return nil
+	}
+	release := d.release
+	d.release = nil
+	return release()
+}
+
+func (d *tableDictionary) RangePrefix(prefix string, fn func(key string, valueIds []ValueID) bool) {
+	i := sort.Search(d.count, func(i int) bool {
+		return string(d.key(i)) >= prefix
+	})
+	for ; i < d.count && bytes.HasPrefix(d.key(i), []byte(prefix)); i++ {
+		if !fn(string(d.key(i)), d.valueIds(i)) {
+			return
+		}
+	}
+}
@@ -0,0 +1,82 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+func writeDictionaryTableFile(t testing.TB, dict Dictionary) string {
+	path := filepath.Join(t.TempDir(), "dictionary.cfgd")
+	f, err := os.Create(path)
+	require.NoError(t, err)
+	require.NoError(t, WriteDictionaryTable(f, dict))
+	require.NoError(t, f.Close())
+	return path
+}
+
+func TestMappedDictionary(t *testing.T) {
+	entries := map[string][]ValueID{
+		"abra":          {1},
+		"abra cadabra":  {1, 2},
+		"":              {0},
+		"ёжик в тумане": {3},
+	}
+	dict, err := OpenMappedDictionary(writeDictionaryTableFile(t, NewMapDictionary(entries)))
+	require.NoError(t, err)
+	defer dict.Close()
+
+	require.Equal(t, 4, dict.Len())
+	require.Equal(t, 3, dict.MaxKeyLength())
+	for key, valueIds := range entries {
+		res, ok := dict.Lookup(key)
+		require.True(t, ok, key)
+		require.Equal(t, valueIds, res)
+	}
+	for _, key := range []string{"a", "abr", "abra cadabra!", "ёжик"} {
+		_, ok := dict.Lookup(key)
+		require.False(t, ok, key)
+	}
+	require.Equal(t, []string{"", "abra", "abra cadabra", "ёжик в тумане"}, dictionaryKeys(dict))
+
+	res := NewAnyOrderDictMatcherFrom(dict, 1).Match(NewInitialState(getTokens("ёжик в тумане")))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{1: {3}})
+}
+
+func TestOpenMappedDictionary_Invalid(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "dictionary.json")
+	require.NoError(t, os.WriteFile(path, []byte(`{"abra": [1]}`), 0o644))
+	_, err := OpenMappedDictionary(path)
+	require.ErrorContains(t, err, "not a dictionary table")
+
+	data, err := os.ReadFile(writeDictionaryTableFile(t, NewMapDictionary(map[string][]ValueID{"abra": {1}})))
+	require.NoError(t, err)
+	require.NoError(t, os.WriteFile(path, data[:len(data)-1], 0o644))
+	_, err = OpenMappedDictionary(path)
+	require.ErrorIs(t, err, errCorruptedTable)
+}
+
+func BenchmarkDictionaryLookup(b *testing.B) {
+	entries := make(map[string][]ValueID, 100000)
+	keys := make([]string, 0, 100000)
+	for i := 0; i < 100000; i++ {
+		key := fmt.Sprintf("улица %d", i)
+		entries[key] = []ValueID{ValueID(i)}
+		keys = append(keys, key)
+	}
+	mapped, err := OpenMappedDictionary(writeDictionaryTableFile(b, NewMapDictionary(entries)))
+	require.NoError(b, err)
+	defer mapped.Close()
+
+	for name, dict := range map[string]Dictionary{"map": NewMapDictionary(entries), "mapped": mapped} {
+		b.Run(name, func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				dict.Lookup(keys[i%len(keys)])
+			}
+		})
+	}
+}
@@ -0,0 +1,151 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"slices"
+	"sort"
+)
+
+type TaxonomyNode struct {
+	Attribute AttributeID
+	Value     ValueID
+}
+
+func (n TaxonomyNode) String() string {
+	return fmt.Sprintf("%d:%d", n.Attribute, n.Value)
+}
+
+// Taxonomy is a forest of attribute values, e.g. region → city → district → metro station.
+type Taxonomy struct {
+	parents    map[TaxonomyNode]TaxonomyNode
+	children   map[TaxonomyNode][]TaxonomyNode
+	attributes map[AttributeID]bool
+}
+
+func NewTaxonomy() *Taxonomy {
+	return &Taxonomy{
+		parents:    make(map[TaxonomyNode]TaxonomyNode),
+		children:   make(map[TaxonomyNode][]TaxonomyNode),
+		attributes: make(map[AttributeID]bool),
+	}
+}
+
+func (t *Taxonomy) Add(child, parent TaxonomyNode) error {
+	if existing, ok := t.parents[child]; ok {
+		if existing == parent {
+			return nil
+		}
+		return fmt.Errorf("taxonomy: %s already has parent %s", child, existing)
+	}
+	if child == parent || t.IsAncestor(child, parent) {
+		return fmt.Errorf("taxonomy: %s → %s makes a cycle", child, parent)
+	}
+	t.parents[child] = parent
+	t.children[parent] = append(t.children[parent], child)
+	t.attributes[child.Attribute] = true
+	t.attributes[parent.Attribute] = true
+	return nil
+}
+
+func (t *Taxonomy) Parent(node TaxonomyNode) (TaxonomyNode, bool) {
+	parent, ok := t.parents[node]
+	return parent, ok
+}
+
+// Ancestors returns the chain of parents starting from the nearest one.
+func (t *Taxonomy) Ancestors(node TaxonomyNode) []TaxonomyNode {
+	var res []TaxonomyNode
+	for parent, ok := t.parents[node]; ok; parent, ok = t.parents[parent] {
+		res = append(res, parent)
+	}
+	return res
+}
+
+func (t *Taxonomy) IsAncestor(ancestor, node TaxonomyNode) bool {
+	for parent, ok := t.parents[node]; ok; parent, ok = t.parents[parent] {
+		if parent == ancestor {
+			return true
+		}
+	}
+	return false
+}
+
+// Descendants returns the whole subtree of the node in breadth-first order.
+func (t *Taxonomy) Descendants(node TaxonomyNode) []TaxonomyNode {
+	var res []TaxonomyNode
+	queue := slices.Clone(t.children[node])
+	for len(queue) > 0 {
+		res = append(res, queue[0])
+		queue = append(queue[1:], t.children[queue[0]]...)
+	}
+	return res
+}
+
+// Expand returns a copy of the memory with the ancestors of every recorded value added,
+// so a match on a district also implies its city and region.
+func (t *Taxonomy) Expand(memory AttrValues) AttrValues {
+	res := make(AttrValues, len(memory))
+	present := make(map[TaxonomyNode]bool)
+	for attributeId, valueIds := range memory {
+		res[attributeId] = append([]ValueID(nil), valueIds...)
+		for _, valueId := range valueIds {
+			present[TaxonomyNode{attributeId, valueId}] = true
+		}
+	}
+	for _, attributeId := range sortedKeys(memory) {
+		for _, valueId := range memory[attributeId] {
+			for _, ancestor := range t.Ancestors(TaxonomyNode{attributeId, valueId}) {
+				if present[ancestor] {
+					continue
+				}
+				present[ancestor] = true
+				res[ancestor.Attribute] = append(res[ancestor.Attribute], ancestor.Value)
+			}
+		}
+	}
+	return res
+}
+
+type Contradiction struct {
+	Attribute AttributeID
+	Values    []ValueID
+}
+
+func (c Contradiction) String() string {
+	return fmt.Sprintf("attribute %d has contradictory values %v", c.Attribute, c.Values)
+}
+
+// Contradictions reports taxonomy attributes holding several values once the memory is expanded,
+// e.g. two regions, two cities of one region, or a district together with a city it does not belong to.
+// Attributes declared Multi in cardinalities, like districts, may hold several values,
+// the others are single-valued.
+func (t *Taxonomy) Contradictions(memory AttrValues, cardinalities map[AttributeID]Cardinality) []Contradiction {
+	var res []Contradiction
+	expanded := t.Expand(memory)
+	for _, attributeId := range sortedKeys(expanded) {
+		if !t.attributes[attributeId] || cardinalities[attributeId] == Multi {
+			continue
+		}
+		if values := uniqueValues(expanded[attributeId]); len(values) > 1 {
+			res = append(res, Contradiction{attributeId, values})
+		}
+	}
+	return res
+}
+
+// Under returns the memory values that are the node itself or lie in its subtree.
+func (t *Taxonomy) Under(memory AttrValues, node TaxonomyNode) AttrValues {
+	res := make(AttrValues)
+	for attributeId, valueIds := range memory {
+		for _, valueId := range valueIds {
+			value := TaxonomyNode{attributeId, valueId}
+			if value == node || t.IsAncestor(node, value) {
+				res[attributeId] = append(res[attributeId], valueId)
+			}
+		}
+	}
+	for _, valueIds := range res {
+		sort.Slice(valueIds, func(i, j int) bool { return valueIds[i] < valueIds[j] })
+	}
+	return res
+}
@@ -0,0 +1,115 @@
+package context_free_grammar
+
+import (
+	"sync"
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+const (
+	attrRegion   AttributeID = 1
+	attrCity     AttributeID = 2
+	attrDistrict AttributeID = 3
+)
+
+func testTaxonomy(t *testing.T) *Taxonomy {
+	taxonomy := NewTaxonomy()
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrCity, 77}, TaxonomyNode{attrRegion, 50}))
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrCity, 78}, TaxonomyNode{attrRegion, 47}))
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrDistrict, 1}, TaxonomyNode{attrCity, 77}))
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrDistrict, 2}, TaxonomyNode{attrCity, 77}))
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrDistrict, 3}, TaxonomyNode{attrCity, 78}))
+	return taxonomy
+}
+
+func TestTaxonomy_Add(t *testing.T) {
+	taxonomy := testTaxonomy(t)
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrDistrict, 1}, TaxonomyNode{attrCity, 77}))
+	require.ErrorContains(t, taxonomy.Add(TaxonomyNode{attrDistrict, 1}, TaxonomyNode{attrCity, 78}), "already has parent 2:77")
+	require.ErrorContains(t, taxonomy.Add(TaxonomyNode{attrRegion, 50}, TaxonomyNode{attrDistrict, 2}), "makes a cycle")
+
+	require.Equal(t, []TaxonomyNode{{attrCity, 77}, {attrRegion, 50}}, taxonomy.Ancestors(TaxonomyNode{attrDistrict, 1}))
+	require.Equal(
+		t,
+		[]TaxonomyNode{{attrCity, 77}, {attrDistrict, 1}, {attrDistrict, 2}},
+		taxonomy.Descendants(TaxonomyNode{attrRegion, 50}),
+	)
+}
+
+func TestTaxonomy_Expand(t *testing.T) {
+	taxonomy := testTaxonomy(t)
+	root := NewFullTextMatcher([]Matcher{
+		NewDictMatcher(map[string][]ValueID{"москва": {77}, "питер": {78}}, attrCity),
+		NewDictMatcher(map[string][]ValueID{"арбат": {1}, "хамовники": {2}, "невский": {3}}, attrDistrict),
+	})
+
+	res := root.Match(NewInitialState(getTokens("москва арбат")))
+	testPositiveParse(t, res)
+	memory := res.Memory().GetStorage()
+	require.Equal(t, AttrValues{attrRegion: {50}, attrCity: {77}, attrDistrict: {1}}, taxonomy.Expand(memory))
+	require.Empty(t, taxonomy.Contradictions(memory, nil))
+	require.Equal(t, AttrValues{attrCity: {77}, attrDistrict: {1}}, taxonomy.Under(memory, TaxonomyNode{attrCity, 77}))
+
+	res = root.Match(NewInitialState(getTokens("арбат хамовники")))
+	memory = res.Memory().GetStorage()
+	require.Equal(t, AttrValues{attrRegion: {50}, attrCity: {77}, attrDistrict: {1, 2}}, taxonomy.Expand(memory))
+	require.Equal(t, AttrValues{attrDistrict: {1, 2}}, taxonomy.Under(memory, TaxonomyNode{attrRegion, 50}))
+	require.Equal(t, []Contradiction{{attrDistrict, []ValueID{1, 2}}}, taxonomy.Contradictions(memory, nil))
+	multiDistricts := map[AttributeID]Cardinality{attrDistrict: Multi}
+	require.Empty(t, taxonomy.Contradictions(memory, multiDistricts))
+
+	res = root.Match(NewInitialState(getTokens("москва невский")))
+	require.Equal(t, []Contradiction{
+		{attrRegion, []ValueID{47, 50}},
+		{attrCity, []ValueID{77, 78}},
+	}, taxonomy.Contradictions(res.Memory().GetStorage(), multiDistricts))
+	require.Equal(t, []Contradiction{
+		{attrRegion, []ValueID{47, 50}},
+		{attrCity, []ValueID{77, 78}},
+		{attrDistrict, []ValueID{1, 3}},
+	}, taxonomy.Contradictions(AttrValues{attrDistrict: {1, 3}}, nil))
+	require.Empty(t, taxonomy.Under(res.Memory().GetStorage(), TaxonomyNode{attrDistrict, 1}))
+}
+
+func TestTaxonomy_ContradictionsCardinality(t *testing.T) {
+	taxonomy := testTaxonomy(t)
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrCity, 79}, TaxonomyNode{attrRegion, 47}))
+
+	require.Equal(t, []Contradiction{
+		{attrRegion, []ValueID{47, 50}},
+	}, taxonomy.Contradictions(AttrValues{attrRegion: {47, 50}}, nil))
+	require.Equal(t, []Contradiction{
+		{attrCity, []ValueID{78, 79}},
+	}, taxonomy.Contradictions(AttrValues{attrCity: {78, 79}}, nil))
+
+	schema, err := NewSchema(
+		AttributeSchema{ID: attrRegion, Name: "region"},
+		AttributeSchema{ID: attrCity, Name: "city", Cardinality: Multi},
+	)
+	require.NoError(t, err)
+	require.Empty(t, taxonomy.Contradictions(AttrValues{attrCity: {78, 79}}, schema.Cardinalities()))
+	require.Equal(t, []Contradiction{
+		{attrRegion, []ValueID{47, 50}},
+	}, taxonomy.Contradictions(AttrValues{attrCity: {77, 78}}, schema.Cardinalities()))
+}
+
+func TestTaxonomy_DescendantsConcurrent(t *testing.T) {
+	taxonomy := testTaxonomy(t)
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrCity, 79}, TaxonomyNode{attrRegion, 47}))
+	require.NoError(t, taxonomy.Add(TaxonomyNode{attrCity, 80}, TaxonomyNode{attrRegion, 47}))
+
+	results := make([][]TaxonomyNode, 8)
+	var wg sync.WaitGroup
+	for i := range results {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			results[i] = taxonomy.Descendants(TaxonomyNode{attrRegion, 47})
+		}(i)
+	}
+	wg.Wait()
+	for _, res := range results {
+		require.Equal(t, []TaxonomyNode{{attrCity, 78}, {attrCity, 79}, {attrCity, 80}, {attrDistrict, 3}}, res)
+	}
+}
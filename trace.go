@@ -0,0 +1,140 @@
+package context_free_grammar
+
+import (
+	"fmt"
+	"io"
+	"maps"
+	"sort"
+	"strings"
+)
+
+type TraceEvent struct {
+	Matcher Matcher
+	Depth   int
+	Input   []string
+	// Start is the position of the first input token on enter. On exit of a match Start and End
+	// delimit the consumed tokens in the parsed input, like in ActionContext.
+	Start       int
+	End         int
+	HasMatch    bool
+	MemoryDelta AttrValues
+}
+
+type Tracer interface {
+	Enter(event TraceEvent)
+	Exit(event TraceEvent)
+}
+
+func Invoke(m Matcher, state MatchState) MatchState {
+	ctx := contextOf(state)
+	if ctx == nil || ctx.tracer == nil {
+		return m.Match(state)
+	}
+
+	event := TraceEvent{
+		Matcher: m,
+		Depth:   ctx.depth,
+		Input:   state.RemainingTokens(),
+		Start:   inputPosition(state),
+	}
+	before := memorySnapshot(state.Memory())
+
+	ctx.tracer.Enter(event)
+	ctx.depth++
+	res := m.Match(state)
+	ctx.depth--
+
+	event.HasMatch = res.HasMatch()
+	event.End = event.Start
+	if res.HasMatch() {
+		_, event.Start, event.End = consumedSpan(state, res.RemainingTokens())
+		event.MemoryDelta = memoryDelta(before, res.Memory())
+	}
+	ctx.tracer.Exit(event)
+	return res
+}
+
+func memorySnapshot(memory MemoryState) AttrValues {
+	if memory == nil {
+		return nil
+	}
+	return maps.Clone(memory.GetStorage())
+}
+
+// memoryDelta returns the values missing from the snapshot, so values replaced
+// by a merge policy are reported as well as appended ones.
+func memoryDelta(before AttrValues, memory MemoryState) AttrValues {
+	if memory == nil {
+		return nil
+	}
+	var delta AttrValues
+	for attributeId, valueIds := range memory.GetStorage() {
+		counts := make(map[ValueID]int, len(before[attributeId]))
+		for _, valueId := range before[attributeId] {
+			counts[valueId]++
+		}
+		for _, valueId := range valueIds {
+			if counts[valueId] > 0 {
+				counts[valueId]--
+				continue
+			}
+			if delta == nil {
+				delta = make(AttrValues)
+			}
+			delta[attributeId] = append(delta[attributeId], valueId)
+		}
+	}
+	return delta
+}
+
+type explainTracer struct {
+	w io.Writer
+}
+
+func NewExplainTracer(w io.Writer) Tracer {
+	return &explainTracer{w}
+}
+
+func (e *explainTracer) Enter(event TraceEvent) {
+	fmt.Fprintf(
+		e.w,
+		"%s> %s @%d %q\n",
+		strings.Repeat("  ", event.Depth),
+		Label(event.Matcher),
+		event.Start,
+		strings.Join(event.Input, " "),
+	)
+}
+
+func (e *explainTracer) Exit(event TraceEvent) {
+	indent := strings.Repeat("  ", event.Depth)
+	if !event.HasMatch {
+		fmt.Fprintf(e.w, "%s< %s no match\n", indent, Label(event.Matcher))
+		return
+	}
+	fmt.Fprintf(e.w, "%s< %s matched [%d:%d]", indent, Label(event.Matcher), event.Start, event.End)
+	if len(event.MemoryDelta) > 0 {
+		fmt.Fprintf(e.w, " +%s", formatAttrValues(event.MemoryDelta))
+	}
+	fmt.Fprintln(e.w)
+}
+
+func Explain(root Matcher, tokens []string) string {
+	var b strings.Builder
+	Invoke(root, NewInitialState(tokens, WithTracer(NewExplainTracer(&b))))
+	return b.String()
+}
+
+func formatAttrValues(values AttrValues) string {
+	attributeIds := make([]AttributeID, 0, len(values))
+	for attributeId := range values {
+		attributeIds = append(attributeIds, attributeId)
+	}
+	sort.Slice(attributeIds, func(i, j int) bool { return attributeIds[i] < attributeIds[j] })
+
+	parts := make([]string, 0, len(attributeIds))
+	for _, attributeId := range attributeIds {
+		parts = append(parts, fmt.Sprintf("%d: %v", attributeId, values[attributeId]))
+	}
+	return "{" + strings.Join(parts, ", ") + "}"
+}
@@ -0,0 +1,112 @@
+package context_free_grammar
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/require"
+)
+
+type recordingTracer struct {
+	entered []TraceEvent
+	exited  []TraceEvent
+}
+
+func (r *recordingTracer) Enter(event TraceEvent) {
+	r.entered = append(r.entered, event)
+}
+
+func (r *recordingTracer) Exit(event TraceEvent) {
+	r.exited = append(r.exited, event)
+}
+
+func TestInvoke_Tracer(t *testing.T) {
+	dict := NewDictMatcher(map[string][]ValueID{"abra": {1}, "cadabra": {2}}, 100500)
+	root := NewSequenceMatcher([]Matcher{
+		NewAllowedWordMatcher("lorem"),
+		dict,
+	})
+
+	tracer := &recordingTracer{}
+	res := Invoke(root, NewInitialState(getTokens("lorem cadabra"), WithTracer(tracer)))
+	testPositiveParse(t, res)
+
+	require.Len(t, tracer.entered, 3)
+	require.Len(t, tracer.exited, 3)
+	require.Equal(t, 0, tracer.entered[0].Depth)
+	require.Equal(t, 1, tracer.entered[2].Depth)
+
+	dictExit := tracer.exited[1]
+	require.Equal(t, dict, dictExit.Matcher)
+	require.True(t, dictExit.HasMatch)
+	require.Equal(t, []string{"cadabra"}, dictExit.Input)
+	require.Equal(t, 1, dictExit.Start)
+	require.Equal(t, 2, dictExit.End)
+	require.Equal(t, AttrValues{100500: {2}}, dictExit.MemoryDelta)
+
+	rootExit := tracer.exited[2]
+	require.Equal(t, 0, rootExit.Start)
+	require.Equal(t, 2, rootExit.End)
+}
+
+func TestInvoke_TracerAnyOrder(t *testing.T) {
+	district := NewAnyOrderDictMatcher(map[string][]ValueID{"центр": {5}}, 3)
+	flat := NewAllowedWordMatcher("квартиру")
+	root := NewSequenceMatcher([]Matcher{NewAllowedWordMatcher("снять"), district, flat})
+
+	tracer := &recordingTracer{}
+	res := Invoke(root, NewInitialState(getTokens("снять квартиру центр"), WithTracer(tracer)))
+	testPositiveParse(t, res)
+
+	spans := make(map[Matcher][2]int)
+	for _, event := range tracer.exited {
+		spans[event.Matcher] = [2]int{event.Start, event.End}
+	}
+	require.Equal(t, [2]int{2, 3}, spans[district])
+	require.Equal(t, [2]int{1, 2}, spans[flat])
+	require.Equal(t, [2]int{0, 3}, spans[root])
+
+	tracer = &recordingTracer{}
+	Invoke(root, NewInitialState(getTokens("снять квартиру центр квартиру"), WithTracer(tracer)))
+	require.Equal(t, 1, tracer.entered[3].Start)
+	require.Equal(t, flat, tracer.entered[3].Matcher)
+}
+
+func TestInvoke_TracerMergePolicy(t *testing.T) {
+	city := NewDictMatcher(map[string][]ValueID{"москва": {77}, "питер": {78}}, 2)
+	root := NewFullTextMatcher([]Matcher{city})
+
+	tracer := &recordingTracer{}
+	res := root.Match(NewInitialState(
+		getTokens("москва питер"),
+		WithTracer(tracer),
+		WithMergePolicies(map[AttributeID]MergePolicy{2: MergeLastWins}),
+	))
+	testPositiveParse(t, res)
+	testDictParserResult(t, res, AttrValues{2: {78}})
+
+	var deltas []AttrValues
+	for _, event := range tracer.exited {
+		if event.Matcher == city {
+			deltas = append(deltas, event.MemoryDelta)
+		}
+	}
+	require.Equal(t, []AttrValues{{2: {77}}, {2: {78}}}, deltas)
+}
+
+func TestExplain(t *testing.T) {
+	root := NewFullTextMatcher([]Matcher{
+		NewAllowedWordMatcher("lorem"),
+		NewDictMatcher(map[string][]ValueID{"abra": {1}}, 100500),
+	})
+
+	expected := `> fullText @0 "abra lorem"
+  > "lorem" @0 "abra lorem"
+  < "lorem" no match
+  > dictionary(attribute=100500) @0 "abra lorem"
+  < dictionary(attribute=100500) matched [0:1] +{100500: [1]}
+  > "lorem" @1 "lorem"
+  < "lorem" matched [1:2]
+< fullText matched [0:2] +{100500: [1]}
+`
+	require.Equal(t, expected, Explain(root, getTokens("abra lorem")))
+}